@@ -2,6 +2,7 @@ package cjxl
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"io/fs"
@@ -9,6 +10,7 @@ import (
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"trance-cli/internal/imgfmt"
 	"trance-cli/internal/logging"
 
 	"github.com/gookit/color"
@@ -16,10 +18,27 @@ import (
 	"github.com/spf13/cobra"
 )
 
+// 按文件内容识别的可处理格式
+var supportedFormats = []imgfmt.Format{imgfmt.JPEG, imgfmt.PNG, imgfmt.BMP, imgfmt.TIFF, imgfmt.GIF, imgfmt.WebP}
+
 type Executor struct {
 	logger    logging.Logger
 	Verbose   bool
 	Recursive bool
+	FixExt    bool
+	LossyWebP bool
+	// 有损转换后删除源文件, 无损转换始终删除源文件
+	RemoveSource bool
+	skipCount    int
+}
+
+// skipError 表示文件被有意跳过, 不计为失败
+type skipError struct {
+	reason string
+}
+
+func (err *skipError) Error() string {
+	return err.reason
 }
 
 func (executor *Executor) Run(cmd *cobra.Command, rawPaths []string) {
@@ -37,7 +56,10 @@ func (executor *Executor) Run(cmd *cobra.Command, rawPaths []string) {
 	if executor.Verbose {
 		for _, srcFilePath := range srcFilePaths {
 			err := executor.processFile(srcFilePath)
-			if err != nil {
+			var skipErr *skipError
+			if errors.As(err, &skipErr) {
+				executor.logSkip(srcFilePath, skipErr.reason)
+			} else if err != nil {
 				executor.logError(srcFilePath, err.Error())
 				hadError = true
 			}
@@ -60,7 +82,12 @@ func (executor *Executor) Run(cmd *cobra.Command, rawPaths []string) {
 		executor.logger.SetState(logging.LoggerStateOutOldLine)
 		for _, srcFilePath := range srcFilePaths {
 			err := executor.processFile(srcFilePath)
-			if err != nil {
+			var skipErr *skipError
+			if errors.As(err, &skipErr) {
+				executor.logSkip(srcFilePath, skipErr.reason)
+				_ = bar.Add(1)
+				executor.logger.SetState(logging.LoggerStateOutOldLine)
+			} else if err != nil {
 				executor.logError(srcFilePath, err.Error())
 				hadError = true
 			} else {
@@ -70,12 +97,21 @@ func (executor *Executor) Run(cmd *cobra.Command, rawPaths []string) {
 		}
 		executor.logger.PrintfOut(logging.LogModeAppend, false, "")
 	}
+	if executor.skipCount > 0 {
+		executor.logger.PrintfOut(logging.LogModeAppend, true, "共跳过 %d 个文件", executor.skipCount)
+	}
 	if hadError {
 		os.Exit(1)
 	}
 }
 
 func (executor *Executor) collectFiles(rawPaths []string) ([]string, error) {
+	acceptor := imgfmt.Acceptor{
+		Logger:    &executor.logger,
+		Supported: supportedFormats,
+		Verbose:   executor.Verbose,
+		FixExt:    executor.FixExt,
+	}
 	var srcFilePaths []string
 	for _, rawPath := range rawPaths {
 		info, err := os.Stat(rawPath)
@@ -98,10 +134,8 @@ func (executor *Executor) collectFiles(rawPaths []string) ([]string, error) {
 						return err
 					}
 					if !entry.IsDir() {
-						ext := strings.ToLower(filepath.Ext(currentPath))
-						switch ext {
-						case ".jpg", ".jpeg", ".png", ".bmp", ".tiff", ".gif", ".webp":
-							srcFilePaths = append(srcFilePaths, currentPath)
+						if srcFilePath, _, ok := acceptor.Accept(currentPath, false); ok {
+							srcFilePaths = append(srcFilePaths, srcFilePath)
 						}
 					}
 					return nil
@@ -116,15 +150,8 @@ func (executor *Executor) collectFiles(rawPaths []string) ([]string, error) {
 				executor.logError(rawPath, "跳过目录")
 			}
 		} else {
-			ext := strings.ToLower(filepath.Ext(rawPath))
-			switch ext {
-			case ".jpg", ".jpeg", ".png", ".bmp", ".tiff", ".gif", ".webp":
-				srcFilePaths = append(srcFilePaths, rawPath)
-				break
-			default:
-				if executor.Verbose {
-					executor.logError(rawPath, "跳过不支持的文件类型")
-				}
+			if srcFilePath, _, ok := acceptor.Accept(rawPath, true); ok {
+				srcFilePaths = append(srcFilePaths, srcFilePath)
 			}
 		}
 	}
@@ -153,15 +180,44 @@ func (executor *Executor) processFile(srcFilePath string) error {
 	}
 	srcFileDir := filepath.Dir(srcFilePath)
 	srcFileBaseName := filepath.Base(srcFilePath)
-	ext := strings.ToLower(filepath.Ext(srcFileBaseName))
 	srcFileBaseNameNoExt := strings.TrimSuffix(srcFileBaseName, filepath.Ext(srcFileBaseName))
 	destFilePath := filepath.Join(srcFileDir, srcFileBaseNameNoExt+".jxl")
 	if _, err := os.Stat(destFilePath); err == nil {
 		return fmt.Errorf("目标文件已存在")
 	}
+	if executor.Verbose {
+		executor.logInProgress(srcFilePath, "探测源文件格式")
+	}
+	source, err := probeSource(srcFilePath)
+	if err != nil {
+		return fmt.Errorf("无法探测源文件格式\n%w", err)
+	}
+	cjxlArgs := []string{"-d", "0"}
+	lossy := false
+	switch source.Format {
+	case imgfmt.WebP:
+		// cjxl 无法保留 WebP 动画帧时序
+		if source.Animated {
+			return &skipError{reason: "动画 WebP 无法保留帧时序, 跳过"}
+		}
+		// 有损 WebP 无损转换只会增大体积
+		if source.Lossy {
+			if !executor.LossyWebP {
+				return &skipError{reason: "有损 WebP 无法无损转换, 跳过"}
+			}
+			cjxlArgs = []string{"-d", "1"}
+			lossy = true
+		}
+	case imgfmt.GIF:
+		// GIF 动画由 cjxl 直接转换, 保留帧时序
+		if source.Animated && executor.Verbose {
+			executor.logInProgress(srcFilePath, "检测到 GIF 动画")
+		}
+	}
 	cjxlInputPath := srcFilePath
 	// PNG 文件先执行 oxipng 优化, 用于移除 IEND 后存在数据
-	if ext == ".png" {
+	// APNG 跳过 oxipng, 避免丢失动画控制块
+	if source.Format == imgfmt.PNG && !source.Animated {
 		if executor.Verbose {
 			executor.logInProgress(srcFilePath, "创建临时文件")
 		}
@@ -206,7 +262,8 @@ func (executor *Executor) processFile(srcFilePath string) error {
 	if executor.Verbose {
 		executor.logInProgress(srcFilePath, "执行 cjxl 命令")
 	}
-	cmd := exec.Command("cjxl", "-d", "0", cjxlInputPath, tmpFilePath)
+	cjxlArgs = append(cjxlArgs, cjxlInputPath, tmpFilePath)
+	cmd := exec.Command("cjxl", cjxlArgs...)
 	var cmdErr bytes.Buffer
 	cmd.Stdout = io.Discard
 	cmd.Stderr = &cmdErr
@@ -221,6 +278,13 @@ func (executor *Executor) processFile(srcFilePath string) error {
 	if err := os.Rename(tmpFilePath, destFilePath); err != nil {
 		return fmt.Errorf("无法写入目标文件\n%w", err)
 	}
+	// 有损转换无法还原源文件, 未指定 --remove-source 时保留
+	if lossy && !executor.RemoveSource {
+		if executor.Verbose {
+			executor.logSuccess(srcFilePath, "有损转换完成, 已保留源文件")
+		}
+		return nil
+	}
 	if err := os.Remove(srcFilePath); err != nil {
 		return fmt.Errorf("无法删除源文件\n%w", err)
 	}
@@ -240,6 +304,12 @@ func (executor *Executor) logSuccess(path string, message string) {
 	executor.logger.PrintfOut(logging.LogModeInPlace, true, "%s %s: %s", successColor.Sprintf("[O]"), path, message)
 }
 
+func (executor *Executor) logSkip(path string, message string) {
+	executor.skipCount++
+	skipColor := color.New(color.FgYellow, color.Bold)
+	executor.logger.PrintfOut(logging.LogModeAppend, true, "%s %s: %s", skipColor.Sprintf("[-]"), path, message)
+}
+
 func (executor *Executor) logError(path string, message string) {
 	errorColor := color.New(color.FgRed, color.Bold)
 	executor.logger.PrintfErr(logging.LogModeAppend, true, "%s %s: %s", errorColor.Sprintf("[X]"), path, message)
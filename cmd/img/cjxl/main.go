@@ -24,6 +24,9 @@ var cmd = &cobra.Command{
 func Register(parentCmd *cobra.Command) {
 	cmd.Flags().BoolVarP(&executor.Verbose, "verbose", "v", false, "verbosely list files processed")
 	cmd.Flags().BoolVarP(&executor.Recursive, "recursion", "r", false, "recurse into directories")
+	cmd.Flags().BoolVar(&executor.FixExt, "fix-ext", false, "rename files whose extension does not match their content")
+	cmd.Flags().BoolVar(&executor.LossyWebP, "lossy-webp", false, "convert lossy WebP with visually lossless distance instead of skipping, keeping the source")
+	cmd.Flags().BoolVar(&executor.RemoveSource, "remove-source", false, "remove lossy WebP sources after --lossy-webp conversion (lossless sources are always removed)")
 	if system.IsCommandAvailable("cjxl") && system.IsCommandAvailable("oxipng") {
 		parentCmd.AddCommand(cmd)
 	}
@@ -0,0 +1,181 @@
+package cjxl
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"trance-cli/internal/imgfmt"
+)
+
+type sourceInfo struct {
+	Format   imgfmt.Format
+	Animated bool
+	Lossy    bool
+}
+
+// probeSource 按文件头识别格式, 并判断是否为动画或有损编码
+func probeSource(path string) (sourceInfo, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return sourceInfo{}, err
+	}
+	defer func(file *os.File) {
+		_ = file.Close()
+	}(file)
+
+	reader := bufio.NewReader(file)
+	header, err := reader.Peek(14)
+	if err != nil && err != io.EOF {
+		return sourceInfo{}, err
+	}
+	format := imgfmt.Detect(header)
+	switch format {
+	case imgfmt.GIF:
+		animated, err := probeGIF(reader)
+		return sourceInfo{Format: format, Animated: animated}, err
+	case imgfmt.PNG:
+		animated, err := probePNG(reader)
+		return sourceInfo{Format: format, Animated: animated}, err
+	case imgfmt.WebP:
+		animated, lossy, err := probeWebP(reader)
+		return sourceInfo{Format: format, Animated: animated, Lossy: lossy}, err
+	}
+	return sourceInfo{Format: format}, nil
+}
+
+// probeGIF 统计图像描述符数量, 多于一帧视为动画
+func probeGIF(reader *bufio.Reader) (bool, error) {
+	// Header(6) + Logical Screen Descriptor(7)
+	screen := make([]byte, 13)
+	if _, err := io.ReadFull(reader, screen); err != nil {
+		return false, fmt.Errorf("GIF 文件头不完整\n%w", err)
+	}
+	if screen[10]&0x80 != 0 {
+		if _, err := reader.Discard(3 << ((screen[10] & 0x07) + 1)); err != nil {
+			return false, fmt.Errorf("GIF 全局调色板不完整\n%w", err)
+		}
+	}
+	frames := 0
+	for {
+		introducer, err := reader.ReadByte()
+		if err != nil {
+			// 截断的文件交由 cjxl 报错
+			return frames > 1, nil
+		}
+		switch introducer {
+		case 0x21:
+			if _, err := reader.ReadByte(); err != nil {
+				return frames > 1, nil
+			}
+		case 0x2C:
+			descriptor := make([]byte, 9)
+			if _, err := io.ReadFull(reader, descriptor); err != nil {
+				return frames > 1, nil
+			}
+			if descriptor[8]&0x80 != 0 {
+				if _, err := reader.Discard(3 << ((descriptor[8] & 0x07) + 1)); err != nil {
+					return frames > 1, nil
+				}
+			}
+			// LZW 最小码长
+			if _, err := reader.ReadByte(); err != nil {
+				return frames > 1, nil
+			}
+			frames++
+			if frames > 1 {
+				return true, nil
+			}
+		case 0x3B:
+			return false, nil
+		default:
+			return false, fmt.Errorf("GIF 数据块类型未知: 0x%02X", introducer)
+		}
+		// 跳过数据子块
+		for {
+			size, err := reader.ReadByte()
+			if err != nil || size == 0 {
+				break
+			}
+			if _, err := reader.Discard(int(size)); err != nil {
+				return frames > 1, nil
+			}
+		}
+	}
+}
+
+// probePNG 在 IDAT 之前查找 acTL 块, 存在即为 APNG
+func probePNG(reader *bufio.Reader) (bool, error) {
+	if _, err := reader.Discard(8); err != nil {
+		return false, err
+	}
+	chunkHeader := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(reader, chunkHeader); err != nil {
+			return false, nil
+		}
+		length := binary.BigEndian.Uint32(chunkHeader[0:4])
+		switch string(chunkHeader[4:8]) {
+		case "acTL":
+			return true, nil
+		case "IDAT", "IEND":
+			return false, nil
+		}
+		// 数据 + CRC
+		if _, err := reader.Discard(int(length) + 4); err != nil {
+			return false, nil
+		}
+	}
+}
+
+// probeWebP 遍历 RIFF 块, 判断是否为动画以及是否为 VP8 有损编码
+func probeWebP(reader *bufio.Reader) (animated bool, lossy bool, err error) {
+	if _, err := reader.Discard(12); err != nil {
+		return false, false, err
+	}
+	chunkHeader := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(reader, chunkHeader); err != nil {
+			return animated, lossy, nil
+		}
+		size := binary.LittleEndian.Uint32(chunkHeader[4:8])
+		switch string(chunkHeader[0:4]) {
+		case "VP8X":
+			flags, err := reader.Peek(1)
+			if err != nil {
+				return animated, lossy, nil
+			}
+			if flags[0]&0x02 != 0 {
+				animated = true
+			}
+		case "ANIM", "ANMF":
+			animated = true
+		case "VP8 ":
+			lossy = true
+		case "VP8L":
+			if !animated {
+				return animated, lossy, nil
+			}
+		}
+		// 动画 WebP 需要检查 ANMF 内部的帧编码, 此处以 VP8X 标志与首个帧为准
+		if string(chunkHeader[0:4]) == "ANMF" {
+			frameHeader, err := reader.Peek(16 + 8)
+			if err != nil {
+				return animated, lossy, nil
+			}
+			switch string(frameHeader[16:20]) {
+			case "VP8 ", "ALPH":
+				lossy = true
+			}
+			return animated, lossy, nil
+		}
+		if lossy {
+			return animated, lossy, nil
+		}
+		// 块数据按偶数字节对齐
+		if _, err := reader.Discard(int(size + size&1)); err != nil {
+			return animated, lossy, nil
+		}
+	}
+}
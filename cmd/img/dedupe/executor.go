@@ -0,0 +1,531 @@
+package dedupe
+
+import (
+	"fmt"
+	"image"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+	"trance-cli/internal/imgfmt"
+	"trance-cli/internal/logging"
+	"trance-cli/internal/system"
+
+	"github.com/gookit/color"
+	"github.com/schollz/progressbar/v3"
+	"github.com/spf13/cobra"
+)
+
+// 按文件内容识别的可处理格式
+var supportedFormats = imgfmt.AllFormats
+
+type Executor struct {
+	logger        logging.Logger
+	hasDjxl       bool
+	Verbose       bool
+	Recursive     bool
+	FixExt        bool
+	Near          bool
+	Threshold     int
+	KeepPolicy    string
+	PreferFormats []string
+	Action        string
+}
+
+type imageFile struct {
+	Path    string
+	Size    int64
+	ModTime time.Time
+	// 用于识别指向同一数据的硬链接
+	Info   os.FileInfo
+	Format string
+	// 文件内容哈希, 相同即逐字节相同
+	ContentHash string
+	// 精确重复判定键, 可还原 JPEG 的 JXL 使用还原后 JPEG 的哈希
+	ExactKey string
+	Pixels   int
+	PHash    uint64
+	HasPHash bool
+}
+
+type duplicateGroup struct {
+	Files []*imageFile
+	// 全部文件 ExactKey 相同
+	Exact bool
+	// 全部文件逐字节相同, 可安全硬链接
+	Identical bool
+}
+
+func (executor *Executor) Run(cmd *cobra.Command, rawPaths []string) {
+	executor.logger = logging.Logger{
+		OutWriter: cmd.OutOrStdout(),
+		ErrWriter: cmd.ErrOrStderr(),
+		State:     logging.LoggerStateNewLine,
+	}
+	switch executor.KeepPolicy {
+	case "largest", "oldest", "format":
+	default:
+		executor.logger.PrintfErr(logging.LogModeAppend, true, "未知保留策略: %s (可选: largest, oldest, format)", executor.KeepPolicy)
+		os.Exit(1)
+	}
+	switch executor.Action {
+	case "dry-run", "delete", "hardlink":
+	default:
+		executor.logger.PrintfErr(logging.LogModeAppend, true, "未知操作: %s (可选: dry-run, delete, hardlink)", executor.Action)
+		os.Exit(1)
+	}
+	if executor.Threshold < 0 || executor.Threshold > 64 {
+		executor.logger.PrintfErr(logging.LogModeAppend, true, "相似度阈值需在 0-64 之间")
+		os.Exit(1)
+	}
+	executor.hasDjxl = system.IsCommandAvailable("djxl")
+
+	srcFilePaths, _ := executor.collectFiles(rawPaths)
+	if len(srcFilePaths) == 0 {
+		return
+	}
+	var hadError bool
+	var files []*imageFile
+	bar := progressbar.NewOptions(len(srcFilePaths),
+		progressbar.OptionSetWriter(cmd.OutOrStdout()),
+		progressbar.OptionShowCount(),
+		progressbar.OptionShowIts(),
+		progressbar.OptionSpinnerType(14),
+		progressbar.OptionSetRenderBlankState(!executor.Verbose),
+		progressbar.OptionSetVisibility(!executor.Verbose),
+		progressbar.OptionSetTheme(progressbar.Theme{
+			Saucer:        "=",
+			SaucerHead:    ">",
+			SaucerPadding: " ",
+			BarStart:      "[",
+			BarEnd:        "]",
+		}),
+	)
+	if !executor.Verbose {
+		executor.logger.SetState(logging.LoggerStateOutOldLine)
+	}
+	for _, srcFilePath := range srcFilePaths {
+		file, err := executor.analyzeFile(srcFilePath)
+		if err != nil {
+			executor.logError(srcFilePath, err.Error())
+			hadError = true
+			continue
+		}
+		if file != nil {
+			files = append(files, file)
+		}
+		if !executor.Verbose {
+			_ = bar.Add(1)
+			executor.logger.SetState(logging.LoggerStateOutOldLine)
+		}
+	}
+
+	groups := executor.groupFiles(files)
+	var reclaimable int64
+	for _, group := range groups {
+		executor.sortGroup(group)
+		reclaimable += executor.reportGroup(group)
+		if err := executor.applyAction(group); err != nil {
+			hadError = true
+		}
+	}
+	executor.logger.PrintfOut(logging.LogModeAppend, true, "共 %d 组重复, %s 可释放", len(groups), formatSize(reclaimable))
+	if hadError {
+		os.Exit(1)
+	}
+}
+
+func (executor *Executor) collectFiles(rawPaths []string) ([]string, error) {
+	acceptor := imgfmt.Acceptor{
+		Logger:    &executor.logger,
+		Supported: supportedFormats,
+		Verbose:   executor.Verbose,
+		// dry-run 模式不修改文件, 只报告扩展名不一致
+		FixExt: executor.FixExt && executor.Action != "dry-run",
+	}
+	var srcFilePaths []string
+	// 参数可能重叠 (如 dir 与 dir/sub), 同一文件或其硬链接只收集一次, 否则会与自身分为一组而被删除
+	seenPaths := make(map[string]bool)
+	seenInfos := make(map[int64][]os.FileInfo)
+	addFile := func(path string, explicit bool) {
+		if absPath, err := filepath.Abs(path); err == nil {
+			if seenPaths[absPath] {
+				return
+			}
+			seenPaths[absPath] = true
+		}
+		srcFilePath, _, ok := acceptor.Accept(path, explicit)
+		if !ok {
+			return
+		}
+		if absPath, err := filepath.Abs(srcFilePath); err == nil {
+			seenPaths[absPath] = true
+		}
+		// 硬链接的大小相同, 按大小分组后比较设备号与 inode
+		if info, err := os.Lstat(srcFilePath); err == nil && info.Mode().IsRegular() {
+			if slices.ContainsFunc(seenInfos[info.Size()], func(seen os.FileInfo) bool {
+				return os.SameFile(seen, info)
+			}) {
+				if executor.Verbose {
+					executor.logSuccess(srcFilePath, "跳过已收集文件的硬链接")
+				}
+				return
+			}
+			seenInfos[info.Size()] = append(seenInfos[info.Size()], info)
+		}
+		srcFilePaths = append(srcFilePaths, srcFilePath)
+	}
+	for _, rawPath := range rawPaths {
+		info, err := os.Stat(rawPath)
+		if err != nil {
+			if os.IsNotExist(err) {
+				executor.logError(rawPath, "文件或目录不存在")
+				continue
+			} else {
+				executor.logError(rawPath, fmt.Sprintf("无法获取文件或目录状态\n%v", err))
+				continue
+			}
+		}
+		if info.IsDir() {
+			if executor.Recursive {
+				if executor.Verbose {
+					executor.logInProgress(rawPath, "递归搜索目录")
+				}
+				err := filepath.WalkDir(rawPath, func(currentPath string, entry fs.DirEntry, err error) error {
+					if err != nil {
+						return err
+					}
+					if !entry.IsDir() {
+						addFile(currentPath, false)
+					}
+					return nil
+				})
+				if err != nil {
+					executor.logError(rawPath, fmt.Sprintf("遍历目录失败\n%v", err))
+				}
+				if executor.Verbose {
+					executor.logSuccess(rawPath, "递归搜索目录完成")
+				}
+			} else {
+				executor.logError(rawPath, "跳过目录")
+			}
+		} else {
+			addFile(rawPath, true)
+		}
+	}
+	return srcFilePaths, nil
+}
+
+func (executor *Executor) analyzeFile(srcFilePath string) (*imageFile, error) {
+	if executor.Verbose {
+		executor.logInProgress(srcFilePath, "确认文件状态")
+	}
+	info, err := os.Lstat(srcFilePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("源文件不存在")
+		}
+		return nil, fmt.Errorf("无法获取源文件状态\n%w", err)
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		if executor.Verbose {
+			executor.logSuccess(srcFilePath, "跳过符号链接")
+		}
+		return nil, nil
+	}
+	if !info.Mode().IsRegular() {
+		return nil, fmt.Errorf("源文件为非常规文件")
+	}
+	sniffed, err := imgfmt.Sniff(srcFilePath)
+	if err != nil {
+		return nil, fmt.Errorf("无法读取源文件\n%w", err)
+	}
+	// 以内容识别的格式参与 --prefer 排序, 名称与扩展名保持一致 (jpg)
+	format := strings.TrimPrefix(sniffed.Ext(), ".")
+	file := &imageFile{
+		Path:    srcFilePath,
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+		Info:    info,
+		Format:  format,
+	}
+	if executor.Verbose {
+		executor.logInProgress(srcFilePath, "计算内容哈希")
+	}
+	file.ContentHash, err = hashFile(srcFilePath)
+	if err != nil {
+		return nil, fmt.Errorf("无法读取源文件\n%w", err)
+	}
+	file.ExactKey = file.ContentHash
+	// cjxl 无损转换的 JPEG 可还原为原文件, 以还原结果参与精确比对
+	if format == "jxl" && executor.hasDjxl {
+		reconstructible, err := imgfmt.HasJPEGReconstruction(srcFilePath)
+		if err == nil && reconstructible {
+			if executor.Verbose {
+				executor.logInProgress(srcFilePath, "还原 JPEG 以计算哈希")
+			}
+			tmpFilePath, err := decodeJXL(srcFilePath, ".jpg")
+			if err != nil {
+				return nil, err
+			}
+			file.ExactKey, err = hashFile(tmpFilePath)
+			_ = os.Remove(tmpFilePath)
+			if err != nil {
+				return nil, fmt.Errorf("无法读取还原的 JPEG\n%w", err)
+			}
+		}
+	}
+	if format != "jxl" {
+		if source, err := os.Open(srcFilePath); err == nil {
+			if config, _, err := image.DecodeConfig(source); err == nil {
+				file.Pixels = config.Width * config.Height
+			}
+			_ = source.Close()
+		}
+	}
+	if executor.Near && (format != "jxl" || executor.hasDjxl) {
+		if executor.Verbose {
+			executor.logInProgress(srcFilePath, "计算感知哈希")
+		}
+		// 解码失败时不计算感知哈希, 文件仍参与精确比对
+		if img, err := decodeImage(srcFilePath, sniffed); err != nil {
+			executor.logWarning(srcFilePath, fmt.Sprintf("无法解码图像, 跳过感知哈希\n%v", err))
+		} else {
+			file.PHash = differenceHash(img)
+			file.HasPHash = true
+			file.Pixels = img.Bounds().Dx() * img.Bounds().Dy()
+		}
+	}
+	if executor.Verbose {
+		executor.logSuccess(srcFilePath, "分析完成")
+	}
+	return file, nil
+}
+
+// groupFiles 使用并查集合并精确重复与感知哈希相近的文件
+func (executor *Executor) groupFiles(files []*imageFile) []*duplicateGroup {
+	parents := make([]int, len(files))
+	for i := range parents {
+		parents[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		for parents[i] != i {
+			parents[i] = parents[parents[i]]
+			i = parents[i]
+		}
+		return i
+	}
+	union := func(i int, j int) {
+		rootI, rootJ := find(i), find(j)
+		if rootI != rootJ {
+			parents[rootJ] = rootI
+		}
+	}
+	exactIndex := make(map[string]int)
+	for i, file := range files {
+		if j, ok := exactIndex[file.ExactKey]; ok {
+			union(j, i)
+		} else {
+			exactIndex[file.ExactKey] = i
+		}
+	}
+	if executor.Near {
+		for i := 0; i < len(files); i++ {
+			if !files[i].HasPHash {
+				continue
+			}
+			for j := i + 1; j < len(files); j++ {
+				if files[j].HasPHash && hammingDistance(files[i].PHash, files[j].PHash) <= executor.Threshold {
+					union(i, j)
+				}
+			}
+		}
+	}
+	groupMap := make(map[int]*duplicateGroup)
+	var groups []*duplicateGroup
+	for i, file := range files {
+		root := find(i)
+		group, ok := groupMap[root]
+		if !ok {
+			group = &duplicateGroup{Exact: true, Identical: true}
+			groupMap[root] = group
+			groups = append(groups, group)
+		}
+		if len(group.Files) > 0 {
+			if group.Files[0].ExactKey != file.ExactKey {
+				group.Exact = false
+			}
+			if group.Files[0].ContentHash != file.ContentHash {
+				group.Identical = false
+			}
+		}
+		group.Files = append(group.Files, file)
+	}
+	var result []*duplicateGroup
+	for _, group := range groups {
+		if len(group.Files) > 1 {
+			result = append(result, group)
+		}
+	}
+	return result
+}
+
+// sortGroup 按保留策略排序, 首个文件为保留项
+func (executor *Executor) sortGroup(group *duplicateGroup) {
+	formatRank := func(format string) int {
+		if index := slices.Index(executor.PreferFormats, format); index != -1 {
+			return index
+		}
+		return len(executor.PreferFormats)
+	}
+	largerFirst := func(a *imageFile, b *imageFile) (bool, bool) {
+		if a.Pixels != b.Pixels {
+			return a.Pixels > b.Pixels, true
+		}
+		if a.Size != b.Size {
+			return a.Size > b.Size, true
+		}
+		return false, false
+	}
+	sort.SliceStable(group.Files, func(i, j int) bool {
+		a, b := group.Files[i], group.Files[j]
+		switch executor.KeepPolicy {
+		case "oldest":
+			if !a.ModTime.Equal(b.ModTime) {
+				return a.ModTime.Before(b.ModTime)
+			}
+		case "format":
+			if rankA, rankB := formatRank(a.Format), formatRank(b.Format); rankA != rankB {
+				return rankA < rankB
+			}
+		}
+		if less, ok := largerFirst(a, b); ok {
+			return less
+		}
+		return a.Path < b.Path
+	})
+}
+
+func (executor *Executor) reportGroup(group *duplicateGroup) int64 {
+	kind := "near"
+	if group.Exact {
+		kind = "exact"
+	}
+	keepColor := color.New(color.FgGreen, color.Bold)
+	removeColor := color.New(color.FgYellow, color.Bold)
+	executor.logger.PrintfOut(logging.LogModeAppend, true, "[%s] %d 个文件", kind, len(group.Files))
+	var reclaimable int64
+	for i, file := range group.Files {
+		mark := removeColor.Sprintf("[-]")
+		if i == 0 {
+			mark = keepColor.Sprintf("[K]")
+		} else {
+			reclaimable += file.Size
+		}
+		executor.logger.PrintfOut(logging.LogModeAppend, true, "  %s %s (%s, %s)", mark, file.Path, formatSize(file.Size), file.ModTime.Format("2006-01-02 15:04"))
+	}
+	return reclaimable
+}
+
+func (executor *Executor) applyAction(group *duplicateGroup) error {
+	var lastErr error
+	keeper := group.Files[0]
+	for _, file := range group.Files[1:] {
+		// 收集时已排除同一文件, 此处再次确认, 避免删除唯一的副本
+		if os.SameFile(keeper.Info, file.Info) {
+			executor.logError(file.Path, "与保留项为同一文件, 跳过")
+			continue
+		}
+		switch executor.Action {
+		case "delete":
+			if err := os.Remove(file.Path); err != nil {
+				executor.logError(file.Path, fmt.Sprintf("无法删除文件\n%v", err))
+				lastErr = err
+			} else if executor.Verbose {
+				executor.logSuccess(file.Path, "已删除")
+			}
+		case "hardlink":
+			// 仅逐字节相同的文件可替换为硬链接
+			if !group.Identical {
+				executor.logError(file.Path, "文件内容不完全相同, 跳过硬链接")
+				continue
+			}
+			if err := replaceWithHardlink(keeper.Path, file.Path); err != nil {
+				executor.logError(file.Path, fmt.Sprintf("无法创建硬链接\n%v", err))
+				lastErr = err
+			} else if executor.Verbose {
+				executor.logSuccess(file.Path, "已替换为硬链接")
+			}
+		}
+	}
+	return lastErr
+}
+
+// replaceWithHardlink 在目标目录创建临时硬链接后原子替换目标文件
+func replaceWithHardlink(keeperPath string, duplicatePath string) error {
+	keeperInfo, err := os.Stat(keeperPath)
+	if err != nil {
+		return err
+	}
+	duplicateInfo, err := os.Stat(duplicatePath)
+	if err != nil {
+		return err
+	}
+	if os.SameFile(keeperInfo, duplicateInfo) {
+		return nil
+	}
+	tmpFile, err := os.CreateTemp(filepath.Dir(duplicatePath), ".dedupe-*")
+	if err != nil {
+		return err
+	}
+	tmpFilePath := tmpFile.Name()
+	_ = tmpFile.Close()
+	_ = os.Remove(tmpFilePath)
+	if err := os.Link(keeperPath, tmpFilePath); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpFilePath, duplicatePath); err != nil {
+		_ = os.Remove(tmpFilePath)
+		return err
+	}
+	return nil
+}
+
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	value := float64(size)
+	units := []string{"KiB", "MiB", "GiB", "TiB"}
+	index := -1
+	for value >= unit && index < len(units)-1 {
+		value /= unit
+		index++
+	}
+	return fmt.Sprintf("%.1f %s", value, units[index])
+}
+
+func (executor *Executor) logInProgress(path string, message string) {
+	inProgressColor := color.New(color.FgCyan, color.Bold)
+	executor.logger.PrintfOut(logging.LogModeInPlace, false, "%s %s: %s", inProgressColor.Sprintf("[>]"), path, message)
+}
+
+func (executor *Executor) logSuccess(path string, message string) {
+	successColor := color.New(color.FgGreen, color.Bold)
+	executor.logger.PrintfOut(logging.LogModeInPlace, true, "%s %s: %s", successColor.Sprintf("[O]"), path, message)
+}
+
+func (executor *Executor) logWarning(path string, message string) {
+	warningColor := color.New(color.FgYellow, color.Bold)
+	executor.logger.PrintfErr(logging.LogModeAppend, true, "%s %s: %s", warningColor.Sprintf("[!]"), path, message)
+}
+
+func (executor *Executor) logError(path string, message string) {
+	errorColor := color.New(color.FgRed, color.Bold)
+	executor.logger.PrintfErr(logging.LogModeAppend, true, "%s %s: %s", errorColor.Sprintf("[X]"), path, message)
+}
@@ -0,0 +1,98 @@
+package dedupe
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"math/bits"
+	"os"
+	"os/exec"
+	"trance-cli/internal/imgfmt"
+
+	_ "golang.org/x/image/bmp"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/tiff"
+	_ "golang.org/x/image/webp"
+)
+
+// hashFile 计算文件内容的 SHA-256
+func hashFile(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer func(file *os.File) {
+		_ = file.Close()
+	}(file)
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// decodeJXL 使用 djxl 解码到临时文件, 返回临时文件路径
+func decodeJXL(path string, ext string) (string, error) {
+	tmpFile, err := os.CreateTemp("", "dedupe-*"+ext)
+	if err != nil {
+		return "", fmt.Errorf("无法创建临时文件\n%w", err)
+	}
+	tmpFilePath := tmpFile.Name()
+	_ = tmpFile.Close()
+	cmd := exec.Command("djxl", path, tmpFilePath)
+	cmd.Stdout = io.Discard
+	cmd.Stderr = io.Discard
+	if err := cmd.Run(); err != nil {
+		_ = os.Remove(tmpFilePath)
+		return "", fmt.Errorf("执行 djxl 命令失败\n%w", err)
+	}
+	return tmpFilePath, nil
+}
+
+// decodeImage 按文件内容识别的格式解码, JXL 经 djxl 转换为 PNG 后解码
+func decodeImage(path string, format imgfmt.Format) (image.Image, error) {
+	if format == imgfmt.JXL {
+		tmpFilePath, err := decodeJXL(path, ".png")
+		if err != nil {
+			return nil, err
+		}
+		defer func() {
+			_ = os.Remove(tmpFilePath)
+		}()
+		path = tmpFilePath
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func(file *os.File) {
+		_ = file.Close()
+	}(file)
+	img, _, err := image.Decode(file)
+	return img, err
+}
+
+// differenceHash 计算 64 位差值哈希: 缩放为 9x8 灰度图, 比较相邻像素亮度
+func differenceHash(img image.Image) uint64 {
+	small := image.NewGray(image.Rect(0, 0, 9, 8))
+	draw.ApproxBiLinear.Scale(small, small.Bounds(), img, img.Bounds(), draw.Src, nil)
+	var hash uint64
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			hash <<= 1
+			if small.GrayAt(x, y).Y < small.GrayAt(x+1, y).Y {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
+
+func hammingDistance(a uint64, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
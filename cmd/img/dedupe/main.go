@@ -0,0 +1,32 @@
+package dedupe
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var executor = &Executor{}
+
+var cmd = &cobra.Command{
+	Use:   "dedupe <file1|dir1> [<file2|dir2> ...]",
+	Short: "Find duplicate images by content hash and perceptual hash",
+	Long:  "",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		executor.Run(cmd, args)
+	},
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return []string{"jpg", "jpeg", "png", "bmp", "tiff", "gif", "webp", "jxl"}, cobra.ShellCompDirectiveFilterFileExt
+	},
+}
+
+func Register(parentCmd *cobra.Command) {
+	cmd.Flags().BoolVarP(&executor.Verbose, "verbose", "v", false, "verbosely list files processed")
+	cmd.Flags().BoolVarP(&executor.Recursive, "recursion", "r", false, "recurse into directories")
+	cmd.Flags().BoolVar(&executor.FixExt, "fix-ext", false, "rename files whose extension does not match their content (only with --action delete or hardlink)")
+	cmd.Flags().BoolVar(&executor.Near, "near", false, "also group near-duplicates by perceptual hash")
+	cmd.Flags().IntVarP(&executor.Threshold, "threshold", "t", 6, "maximum perceptual hash distance (0-64) for near-duplicates")
+	cmd.Flags().StringVarP(&executor.KeepPolicy, "keep", "k", "largest", "which file to keep in each group (largest, oldest, format)")
+	cmd.Flags().StringSliceVar(&executor.PreferFormats, "prefer", []string{"jxl", "png", "webp", "jpg", "tiff", "bmp", "gif"}, "format preference order for --keep format")
+	cmd.Flags().StringVarP(&executor.Action, "action", "a", "dry-run", "what to do with duplicates (dry-run, delete, hardlink)")
+	parentCmd.AddCommand(cmd)
+}
@@ -0,0 +1,262 @@
+package djxl
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"trance-cli/internal/imgfmt"
+	"trance-cli/internal/logging"
+
+	"github.com/gookit/color"
+	"github.com/schollz/progressbar/v3"
+	"github.com/spf13/cobra"
+)
+
+// 按文件内容识别的可处理格式
+var supportedFormats = []imgfmt.Format{imgfmt.JXL}
+
+type Executor struct {
+	logger       logging.Logger
+	Verbose      bool
+	Recursive    bool
+	FixExt       bool
+	RemoveSource bool
+	skipCount    int
+}
+
+// skipError 表示文件被有意跳过, 不计为失败
+type skipError struct {
+	reason string
+}
+
+func (err *skipError) Error() string {
+	return err.reason
+}
+
+func (executor *Executor) Run(cmd *cobra.Command, rawPaths []string) {
+	executor.logger = logging.Logger{
+		OutWriter: cmd.OutOrStdout(),
+		ErrWriter: cmd.ErrOrStderr(),
+		State:     logging.LoggerStateNewLine,
+	}
+
+	srcFilePaths, _ := executor.collectFiles(rawPaths)
+	if len(srcFilePaths) == 0 {
+		return
+	}
+	var hadError bool
+	if executor.Verbose {
+		for _, srcFilePath := range srcFilePaths {
+			err := executor.processFile(srcFilePath)
+			var skipErr *skipError
+			if errors.As(err, &skipErr) {
+				executor.logSkip(srcFilePath, skipErr.reason)
+			} else if err != nil {
+				executor.logError(srcFilePath, err.Error())
+				hadError = true
+			}
+		}
+	} else {
+		bar := progressbar.NewOptions(len(srcFilePaths),
+			progressbar.OptionSetWriter(cmd.OutOrStdout()),
+			progressbar.OptionShowCount(),
+			progressbar.OptionShowIts(),
+			progressbar.OptionSpinnerType(14),
+			progressbar.OptionSetRenderBlankState(true),
+			progressbar.OptionSetTheme(progressbar.Theme{
+				Saucer:        "=",
+				SaucerHead:    ">",
+				SaucerPadding: " ",
+				BarStart:      "[",
+				BarEnd:        "]",
+			}),
+		)
+		executor.logger.SetState(logging.LoggerStateOutOldLine)
+		for _, srcFilePath := range srcFilePaths {
+			err := executor.processFile(srcFilePath)
+			var skipErr *skipError
+			if errors.As(err, &skipErr) {
+				executor.logSkip(srcFilePath, skipErr.reason)
+				_ = bar.Add(1)
+				executor.logger.SetState(logging.LoggerStateOutOldLine)
+			} else if err != nil {
+				executor.logError(srcFilePath, err.Error())
+				hadError = true
+			} else {
+				_ = bar.Add(1)
+				executor.logger.SetState(logging.LoggerStateOutOldLine)
+			}
+		}
+		executor.logger.PrintfOut(logging.LogModeAppend, false, "")
+	}
+	if executor.skipCount > 0 {
+		executor.logger.PrintfOut(logging.LogModeAppend, true, "共跳过 %d 个文件", executor.skipCount)
+	}
+	if hadError {
+		os.Exit(1)
+	}
+}
+
+func (executor *Executor) collectFiles(rawPaths []string) ([]string, error) {
+	acceptor := imgfmt.Acceptor{
+		Logger:    &executor.logger,
+		Supported: supportedFormats,
+		Verbose:   executor.Verbose,
+		FixExt:    executor.FixExt,
+	}
+	var srcFilePaths []string
+	for _, rawPath := range rawPaths {
+		info, err := os.Stat(rawPath)
+		if err != nil {
+			if os.IsNotExist(err) {
+				executor.logError(rawPath, "文件或目录不存在")
+				continue
+			} else {
+				executor.logError(rawPath, fmt.Sprintf("无法获取文件或目录状态\n%v", err))
+				continue
+			}
+		}
+		if info.IsDir() {
+			if executor.Recursive {
+				if executor.Verbose {
+					executor.logInProgress(rawPath, "递归搜索目录")
+				}
+				err := filepath.WalkDir(rawPath, func(currentPath string, entry fs.DirEntry, err error) error {
+					if err != nil {
+						return err
+					}
+					if !entry.IsDir() {
+						if srcFilePath, _, ok := acceptor.Accept(currentPath, false); ok {
+							srcFilePaths = append(srcFilePaths, srcFilePath)
+						}
+					}
+					return nil
+				})
+				if err != nil {
+					executor.logError(rawPath, fmt.Sprintf("遍历目录失败\n%v", err))
+				}
+				if executor.Verbose {
+					executor.logSuccess(rawPath, "递归搜索目录完成")
+				}
+			} else {
+				executor.logError(rawPath, "跳过目录")
+			}
+		} else {
+			if srcFilePath, _, ok := acceptor.Accept(rawPath, true); ok {
+				srcFilePaths = append(srcFilePaths, srcFilePath)
+			}
+		}
+	}
+	return srcFilePaths, nil
+}
+
+func (executor *Executor) processFile(srcFilePath string) error {
+	if executor.Verbose {
+		executor.logInProgress(srcFilePath, "确认文件状态")
+	}
+	info, err := os.Lstat(srcFilePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("源文件不存在")
+		}
+		return fmt.Errorf("无法获取源文件状态\n%w", err)
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		if executor.Verbose {
+			executor.logSuccess(srcFilePath, "跳过符号链接")
+		}
+		return nil
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("源文件为非常规文件")
+	}
+	if executor.Verbose {
+		executor.logInProgress(srcFilePath, "检查 JPEG 重建数据")
+	}
+	// 存在 JPEG 重建数据时逐字节还原 JPEG, 否则解码为 PNG
+	reconstructible, err := imgfmt.HasJPEGReconstruction(srcFilePath)
+	if err != nil {
+		return fmt.Errorf("无法解析 JXL 文件\n%w", err)
+	}
+	destExt := ".png"
+	if reconstructible {
+		destExt = ".jpg"
+	}
+	srcFileDir := filepath.Dir(srcFilePath)
+	srcFileBaseName := filepath.Base(srcFilePath)
+	srcFileBaseNameNoExt := strings.TrimSuffix(srcFileBaseName, filepath.Ext(srcFileBaseName))
+	destFilePath := filepath.Join(srcFileDir, srcFileBaseNameNoExt+destExt)
+	if _, err := os.Stat(destFilePath); err == nil {
+		return &skipError{reason: fmt.Sprintf("目标文件已存在: %s", filepath.Base(destFilePath))}
+	}
+	if executor.Verbose {
+		executor.logInProgress(srcFilePath, "创建临时文件")
+	}
+	tmpFile, err := os.CreateTemp(srcFileDir, "djxl-*"+destExt)
+	if err != nil {
+		return fmt.Errorf("无法创建临时文件\n%w", err)
+	}
+	defer func() {
+		_ = os.Remove(tmpFile.Name())
+	}()
+	tmpFilePath := tmpFile.Name()
+	_ = tmpFile.Close()
+	if executor.Verbose {
+		executor.logInProgress(srcFilePath, "执行 djxl 命令")
+	}
+	cmd := exec.Command("djxl", srcFilePath, tmpFilePath)
+	var cmdErr bytes.Buffer
+	cmd.Stdout = io.Discard
+	cmd.Stderr = &cmdErr
+	if err := cmd.Run(); err != nil {
+		cmdErrMsg := cmdErr.String()
+		if !strings.HasSuffix(cmdErrMsg, "\n") {
+			cmdErrMsg += "\n"
+		}
+		executor.logger.PrintfErr(logging.LogModeAppend, false, "%s", cmdErrMsg)
+		return fmt.Errorf("执行 djxl 命令失败\n%w", err)
+	}
+	if err := os.Rename(tmpFilePath, destFilePath); err != nil {
+		return fmt.Errorf("无法写入目标文件\n%w", err)
+	}
+	if executor.RemoveSource {
+		if err := os.Remove(srcFilePath); err != nil {
+			return fmt.Errorf("无法删除源文件\n%w", err)
+		}
+	}
+	if executor.Verbose {
+		if reconstructible {
+			executor.logSuccess(srcFilePath, "已还原原始 JPEG")
+		} else {
+			executor.logSuccess(srcFilePath, "已解码为 PNG")
+		}
+	}
+	return nil
+}
+
+func (executor *Executor) logInProgress(path string, message string) {
+	inProgressColor := color.New(color.FgCyan, color.Bold)
+	executor.logger.PrintfOut(logging.LogModeInPlace, false, "%s %s: %s", inProgressColor.Sprintf("[>]"), path, message)
+}
+
+func (executor *Executor) logSuccess(path string, message string) {
+	successColor := color.New(color.FgGreen, color.Bold)
+	executor.logger.PrintfOut(logging.LogModeInPlace, true, "%s %s: %s", successColor.Sprintf("[O]"), path, message)
+}
+
+func (executor *Executor) logSkip(path string, message string) {
+	executor.skipCount++
+	skipColor := color.New(color.FgYellow, color.Bold)
+	executor.logger.PrintfOut(logging.LogModeAppend, true, "%s %s: %s", skipColor.Sprintf("[-]"), path, message)
+}
+
+func (executor *Executor) logError(path string, message string) {
+	errorColor := color.New(color.FgRed, color.Bold)
+	executor.logger.PrintfErr(logging.LogModeAppend, true, "%s %s: %s", errorColor.Sprintf("[X]"), path, message)
+}
@@ -0,0 +1,32 @@
+package djxl
+
+import (
+	"trance-cli/internal/system"
+
+	"github.com/spf13/cobra"
+)
+
+var executor = &Executor{}
+
+var cmd = &cobra.Command{
+	Use:   "djxl <file1> [<file2> ...]",
+	Short: "Restore JXL files to JPEG or PNG using djxl",
+	Long:  "",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		executor.Run(cmd, args)
+	},
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return []string{"jxl"}, cobra.ShellCompDirectiveFilterFileExt
+	},
+}
+
+func Register(parentCmd *cobra.Command) {
+	cmd.Flags().BoolVarP(&executor.Verbose, "verbose", "v", false, "verbosely list files processed")
+	cmd.Flags().BoolVarP(&executor.Recursive, "recursion", "r", false, "recurse into directories")
+	cmd.Flags().BoolVar(&executor.FixExt, "fix-ext", false, "rename files whose extension does not match their content")
+	cmd.Flags().BoolVar(&executor.RemoveSource, "remove-source", false, "remove the JXL file after a successful conversion")
+	if system.IsCommandAvailable("djxl") {
+		parentCmd.AddCommand(cmd)
+	}
+}
@@ -2,7 +2,12 @@ package img
 
 import (
 	"trance-cli/cmd/img/cjxl"
+	"trance-cli/cmd/img/dedupe"
+	"trance-cli/cmd/img/djxl"
+	"trance-cli/cmd/img/meta"
 	"trance-cli/cmd/img/noexif"
+	"trance-cli/cmd/img/organize"
+	"trance-cli/cmd/img/resize"
 
 	"github.com/spf13/cobra"
 )
@@ -15,6 +20,11 @@ var cmd = &cobra.Command{
 
 func Register(parentCmd *cobra.Command) {
 	cjxl.Register(cmd)
+	djxl.Register(cmd)
+	dedupe.Register(cmd)
 	noexif.Register(cmd)
+	meta.Register(cmd)
+	organize.Register(cmd)
+	resize.Register(cmd)
 	parentCmd.AddCommand(cmd)
 }
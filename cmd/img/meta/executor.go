@@ -0,0 +1,299 @@
+package meta
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"trance-cli/internal/imgfmt"
+	"trance-cli/internal/logging"
+	"trance-cli/internal/metadata"
+
+	"github.com/gookit/color"
+	"github.com/schollz/progressbar/v3"
+	"github.com/spf13/cobra"
+)
+
+// 单次 exiftool 调用读取的文件数
+const batchSize = 64
+
+// 审计的隐私相关分类
+var auditCategoryNames = []string{"gps", "serial", "owner", "datetime", "thumbnail"}
+
+// 按文件内容识别的可处理格式
+var supportedFormats = imgfmt.AllFormats
+
+type Executor struct {
+	logger    logging.Logger
+	Verbose   bool
+	Recursive bool
+	JSON      bool
+	All       bool
+}
+
+type TagReport struct {
+	Tag   string `json:"tag"`
+	Value string `json:"value"`
+}
+
+type FileReport struct {
+	Path       string                 `json:"path"`
+	Categories map[string][]TagReport `json:"categories"`
+}
+
+// ErrorReport 为无法读取元数据的文件
+type ErrorReport struct {
+	Path  string `json:"path"`
+	Error string `json:"error"`
+}
+
+type Report struct {
+	Files   []FileReport   `json:"files"`
+	Errors  []ErrorReport  `json:"errors"`
+	Scanned int            `json:"scanned"`
+	Flagged int            `json:"flagged"`
+	Counts  map[string]int `json:"counts"`
+}
+
+func (executor *Executor) Run(cmd *cobra.Command, rawPaths []string) {
+	executor.logger = logging.Logger{
+		OutWriter: cmd.OutOrStdout(),
+		ErrWriter: cmd.ErrOrStderr(),
+		State:     logging.LoggerStateNewLine,
+	}
+	// JSON 模式下标准输出只包含报告, 进度与日志输出到标准错误
+	if executor.JSON {
+		executor.logger.OutWriter = cmd.ErrOrStderr()
+	}
+
+	categories, err := metadata.LookupCategories(auditCategoryNames)
+	if err != nil {
+		executor.logger.PrintfErr(logging.LogModeAppend, true, "%s", err.Error())
+		os.Exit(1)
+	}
+	srcFilePaths, _ := executor.collectFiles(rawPaths)
+	if len(srcFilePaths) == 0 {
+		return
+	}
+	report := Report{
+		Files:  []FileReport{},
+		Errors: []ErrorReport{},
+		Counts: make(map[string]int),
+	}
+	for _, category := range categories {
+		report.Counts[category.Name] = 0
+	}
+	var hadError bool
+	// 报告输出到标准输出, 进度条输出到标准错误
+	bar := progressbar.NewOptions(len(srcFilePaths),
+		progressbar.OptionSetWriter(cmd.ErrOrStderr()),
+		progressbar.OptionShowCount(),
+		progressbar.OptionShowIts(),
+		progressbar.OptionSpinnerType(14),
+		progressbar.OptionSetRenderBlankState(!executor.Verbose),
+		progressbar.OptionSetVisibility(!executor.Verbose),
+		progressbar.OptionClearOnFinish(),
+		progressbar.OptionSetTheme(progressbar.Theme{
+			Saucer:        "=",
+			SaucerHead:    ">",
+			SaucerPadding: " ",
+			BarStart:      "[",
+			BarEnd:        "]",
+		}),
+	)
+	for start := 0; start < len(srcFilePaths); start += batchSize {
+		end := min(start+batchSize, len(srcFilePaths))
+		batch := srcFilePaths[start:end]
+		if executor.Verbose {
+			executor.logInProgress(batch[0], fmt.Sprintf("读取元数据 (%d/%d)", end, len(srcFilePaths)))
+		}
+		results, failed := readBatch(batch)
+		for _, path := range batch {
+			if message, ok := failed[path]; ok {
+				executor.logError(path, message)
+				report.Errors = append(report.Errors, ErrorReport{Path: path, Error: message})
+				hadError = true
+			}
+		}
+		for _, result := range results {
+			fileReport := FileReport{
+				Path:       result.SourceFile,
+				Categories: make(map[string][]TagReport),
+			}
+			for _, tag := range result.Tags {
+				for _, category := range categories {
+					if category.Match(tag) {
+						fileReport.Categories[category.Name] = append(fileReport.Categories[category.Name], TagReport{
+							Tag:   tag.Key(),
+							Value: tag.Value,
+						})
+					}
+				}
+			}
+			report.Scanned++
+			for name := range fileReport.Categories {
+				report.Counts[name]++
+			}
+			if len(fileReport.Categories) > 0 {
+				report.Flagged++
+			}
+			if len(fileReport.Categories) > 0 || executor.All {
+				report.Files = append(report.Files, fileReport)
+			}
+		}
+		_ = bar.Add(len(batch))
+	}
+	_ = bar.Finish()
+	if executor.Verbose {
+		executor.logSuccess(fmt.Sprintf("%d 个文件", report.Scanned), "读取元数据完成")
+	}
+	if executor.JSON {
+		encoder := json.NewEncoder(cmd.OutOrStdout())
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			executor.logger.PrintfErr(logging.LogModeAppend, true, "%s", err.Error())
+			hadError = true
+		}
+	} else {
+		executor.printTable(cmd, report, categories)
+	}
+	if hadError {
+		os.Exit(1)
+	}
+}
+
+// readBatch 读取一批文件的元数据, 整批失败时逐个文件重试, 返回失败文件及原因
+// exiftool 跳过的文件同样视为失败, 避免其从报告中消失
+func readBatch(batch []string) ([]metadata.FileTags, map[string]string) {
+	failed := make(map[string]string)
+	results, err := metadata.ReadTags(batch...)
+	if err != nil {
+		results = nil
+		for _, path := range batch {
+			fileResults, err := metadata.ReadTags(path)
+			if err != nil {
+				failed[path] = err.Error()
+				continue
+			}
+			results = append(results, fileResults...)
+		}
+	}
+	returned := make(map[string]bool, len(results))
+	for _, result := range results {
+		// exiftool 在 Windows 上以 / 分隔路径
+		returned[filepath.ToSlash(result.SourceFile)] = true
+	}
+	for _, path := range batch {
+		if _, ok := failed[path]; !ok && !returned[filepath.ToSlash(path)] {
+			failed[path] = "exiftool 未返回元数据"
+		}
+	}
+	return results, failed
+}
+
+func (executor *Executor) printTable(cmd *cobra.Command, report Report, categories []metadata.Category) {
+	writer := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+	header := []string{"FILE"}
+	for _, category := range categories {
+		header = append(header, strings.ToUpper(category.Name))
+	}
+	_, _ = fmt.Fprintln(writer, strings.Join(header, "\t"))
+	for _, fileReport := range report.Files {
+		row := []string{fileReport.Path}
+		for _, category := range categories {
+			tags := fileReport.Categories[category.Name]
+			if len(tags) == 0 {
+				row = append(row, "-")
+			} else if category.Name == "gps" || category.Name == "thumbnail" {
+				row = append(row, fmt.Sprintf("yes(%d)", len(tags)))
+			} else {
+				// 显示首个标签的值, 便于识别
+				value := tags[0].Value
+				if len([]rune(value)) > 24 {
+					value = string([]rune(value)[:24]) + "..."
+				}
+				if len(tags) > 1 {
+					value = fmt.Sprintf("%s (+%d)", value, len(tags)-1)
+				}
+				row = append(row, value)
+			}
+		}
+		_, _ = fmt.Fprintln(writer, strings.Join(row, "\t"))
+	}
+	summary := []string{fmt.Sprintf("TOTAL %d/%d", report.Flagged, report.Scanned)}
+	for _, category := range categories {
+		summary = append(summary, fmt.Sprintf("%d", report.Counts[category.Name]))
+	}
+	_, _ = fmt.Fprintln(writer, strings.Join(summary, "\t"))
+	_ = writer.Flush()
+}
+
+func (executor *Executor) collectFiles(rawPaths []string) ([]string, error) {
+	acceptor := imgfmt.Acceptor{
+		Logger:    &executor.logger,
+		Supported: supportedFormats,
+		Verbose:   executor.Verbose,
+	}
+	var srcFilePaths []string
+	for _, rawPath := range rawPaths {
+		info, err := os.Stat(rawPath)
+		if err != nil {
+			if os.IsNotExist(err) {
+				executor.logError(rawPath, "文件或目录不存在")
+				continue
+			} else {
+				executor.logError(rawPath, fmt.Sprintf("无法获取文件或目录状态\n%v", err))
+				continue
+			}
+		}
+		if info.IsDir() {
+			if executor.Recursive {
+				if executor.Verbose {
+					executor.logInProgress(rawPath, "递归搜索目录")
+				}
+				err := filepath.WalkDir(rawPath, func(currentPath string, entry fs.DirEntry, err error) error {
+					if err != nil {
+						return err
+					}
+					if !entry.IsDir() {
+						if srcFilePath, _, ok := acceptor.Accept(currentPath, false); ok {
+							srcFilePaths = append(srcFilePaths, srcFilePath)
+						}
+					}
+					return nil
+				})
+				if err != nil {
+					executor.logError(rawPath, fmt.Sprintf("遍历目录失败\n%v", err))
+				}
+				if executor.Verbose {
+					executor.logSuccess(rawPath, "递归搜索目录完成")
+				}
+			} else {
+				executor.logError(rawPath, "跳过目录")
+			}
+		} else {
+			if srcFilePath, _, ok := acceptor.Accept(rawPath, true); ok {
+				srcFilePaths = append(srcFilePaths, srcFilePath)
+			}
+		}
+	}
+	return srcFilePaths, nil
+}
+
+func (executor *Executor) logInProgress(path string, message string) {
+	inProgressColor := color.New(color.FgCyan, color.Bold)
+	executor.logger.PrintfOut(logging.LogModeInPlace, false, "%s %s: %s", inProgressColor.Sprintf("[>]"), path, message)
+}
+
+func (executor *Executor) logSuccess(path string, message string) {
+	successColor := color.New(color.FgGreen, color.Bold)
+	executor.logger.PrintfOut(logging.LogModeInPlace, true, "%s %s: %s", successColor.Sprintf("[O]"), path, message)
+}
+
+func (executor *Executor) logError(path string, message string) {
+	errorColor := color.New(color.FgRed, color.Bold)
+	executor.logger.PrintfErr(logging.LogModeAppend, true, "%s %s: %s", errorColor.Sprintf("[X]"), path, message)
+}
@@ -0,0 +1,32 @@
+package meta
+
+import (
+	"trance-cli/internal/system"
+
+	"github.com/spf13/cobra"
+)
+
+var executor = &Executor{}
+
+var cmd = &cobra.Command{
+	Use:   "meta <file1|dir1> [<file2|dir2> ...]",
+	Short: "Audit privacy-relevant metadata in images using exiftool",
+	Long:  "",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		executor.Run(cmd, args)
+	},
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return []string{"jpg", "jpeg", "png", "bmp", "tiff", "gif", "webp", "jxl"}, cobra.ShellCompDirectiveFilterFileExt
+	},
+}
+
+func Register(parentCmd *cobra.Command) {
+	cmd.Flags().BoolVarP(&executor.Verbose, "verbose", "v", false, "verbosely list files processed")
+	cmd.Flags().BoolVarP(&executor.Recursive, "recursion", "r", false, "recurse into directories")
+	cmd.Flags().BoolVarP(&executor.JSON, "json", "j", false, "print the report as JSON")
+	cmd.Flags().BoolVarP(&executor.All, "all", "a", false, "include files without privacy-relevant tags in the report")
+	if system.IsCommandAvailable("exiftool") {
+		parentCmd.AddCommand(cmd)
+	}
+}
@@ -9,17 +9,32 @@ import (
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"trance-cli/internal/imgfmt"
 	"trance-cli/internal/logging"
+	"trance-cli/internal/metadata"
+	"trance-cli/internal/system"
 
 	"github.com/gookit/color"
 	"github.com/schollz/progressbar/v3"
 	"github.com/spf13/cobra"
 )
 
+// 按文件内容识别的可处理格式
+var supportedFormats = imgfmt.AllFormats
+
 type Executor struct {
-	logger    logging.Logger
-	Verbose   bool
-	Recursive bool
+	logger       logging.Logger
+	exiftoolArgs []string
+	hasExiftool  bool
+	Verbose      bool
+	Recursive    bool
+	FixExt       bool
+	Keep         []string
+	Strip        []string
+	GPSOnly      bool
+	Preview      bool
+	UseExiftool  bool
+	BackupDir    string
 }
 
 func (executor *Executor) Run(cmd *cobra.Command, rawPaths []string) {
@@ -28,13 +43,32 @@ func (executor *Executor) Run(cmd *cobra.Command, rawPaths []string) {
 		ErrWriter: cmd.ErrOrStderr(),
 		State:     logging.LoggerStateNewLine,
 	}
+	exiftoolArgs, err := executor.buildExiftoolArgs()
+	if err != nil {
+		executor.logger.PrintfErr(logging.LogModeAppend, true, "%s", err.Error())
+		os.Exit(1)
+	}
+	executor.exiftoolArgs = exiftoolArgs
+	executor.hasExiftool = system.IsCommandAvailable("exiftool")
+	if !executor.hasExiftool && (executor.Preview || executor.UseExiftool || !executor.isFullStrip()) {
+		executor.logger.PrintfErr(logging.LogModeAppend, true, "未找到 exiftool, 仅支持对 JPEG/PNG/WebP 移除全部元数据")
+		os.Exit(1)
+	}
 
 	srcFilePaths, _ := executor.collectFiles(rawPaths)
 	if len(srcFilePaths) == 0 {
 		return
 	}
 	var hadError bool
-	if executor.Verbose {
+	if executor.Preview {
+		for _, srcFilePath := range srcFilePaths {
+			err := executor.previewFile(srcFilePath)
+			if err != nil {
+				executor.logError(srcFilePath, err.Error())
+				hadError = true
+			}
+		}
+	} else if executor.Verbose {
 		for _, srcFilePath := range srcFilePaths {
 			err := executor.processFile(srcFilePath)
 			if err != nil {
@@ -75,7 +109,69 @@ func (executor *Executor) Run(cmd *cobra.Command, rawPaths []string) {
 	}
 }
 
+// buildExiftoolArgs 根据 --keep/--strip/--gps-only 生成 exiftool 删除参数
+func (executor *Executor) buildExiftoolArgs() ([]string, error) {
+	modeCount := 0
+	if len(executor.Keep) > 0 {
+		modeCount++
+	}
+	if len(executor.Strip) > 0 {
+		modeCount++
+	}
+	if executor.GPSOnly {
+		modeCount++
+	}
+	if modeCount > 1 {
+		return nil, fmt.Errorf("--keep, --strip 与 --gps-only 不能同时使用")
+	}
+	var args []string
+	switch {
+	case executor.GPSOnly:
+		categories, _ := metadata.LookupCategories([]string{"gps"})
+		for _, category := range categories {
+			args = append(args, category.DeleteArgs...)
+		}
+	case len(executor.Strip) > 0:
+		categories, err := metadata.LookupCategories(executor.Strip)
+		if err != nil {
+			return nil, err
+		}
+		for _, category := range categories {
+			args = append(args, category.DeleteArgs...)
+		}
+	default:
+		categories, err := metadata.LookupCategories(executor.Keep)
+		if err != nil {
+			return nil, err
+		}
+		// 先删除全部, 独立分组通过排除保留, 其余标签再从原文件复制回来
+		args = append(args, "-all=")
+		var copyArgs []string
+		for _, category := range categories {
+			args = append(args, category.KeepExcludeArgs...)
+			copyArgs = append(copyArgs, category.KeepCopyArgs...)
+		}
+		if len(copyArgs) > 0 {
+			args = append(args, "-tagsfromfile", "@")
+			args = append(args, copyArgs...)
+		}
+	}
+	return args, nil
+}
+
+// isFullStrip 是否为移除全部元数据模式, 仅此模式可使用进程内实现
+func (executor *Executor) isFullStrip() bool {
+	return len(executor.Keep) == 0 && len(executor.Strip) == 0 && !executor.GPSOnly
+}
+
 func (executor *Executor) collectFiles(rawPaths []string) ([]string, error) {
+	acceptor := imgfmt.Acceptor{
+		Logger:    &executor.logger,
+		Supported: supportedFormats,
+		Verbose:   executor.Verbose,
+		// 预览模式不修改文件, 只报告扩展名不一致
+		FixExt: executor.FixExt && !executor.Preview,
+	}
 	var srcFilePaths []string
 	for _, rawPath := range rawPaths {
 		info, err := os.Stat(rawPath)
@@ -98,10 +194,8 @@ func (executor *Executor) collectFiles(rawPaths []string) ([]string, error) {
 						return err
 					}
 					if !entry.IsDir() {
-						ext := strings.ToLower(filepath.Ext(currentPath))
-						switch ext {
-						case ".jpg", ".jpeg", ".png", ".bmp", ".tiff", ".gif", ".webp", ".jxl":
-							srcFilePaths = append(srcFilePaths, currentPath)
+						if srcFilePath, _, ok := acceptor.Accept(currentPath, false); ok {
+							srcFilePaths = append(srcFilePaths, srcFilePath)
 						}
 					}
 					return nil
@@ -116,15 +210,8 @@ func (executor *Executor) collectFiles(rawPaths []string) ([]string, error) {
 				executor.logError(rawPath, "跳过目录")
 			}
 		} else {
-			ext := strings.ToLower(filepath.Ext(rawPath))
-			switch ext {
-			case ".jpg", ".jpeg", ".png", ".bmp", ".tiff", ".gif", ".webp", ".jxl":
-				srcFilePaths = append(srcFilePaths, rawPath)
-				break
-			default:
-				if executor.Verbose {
-					executor.logError(rawPath, "跳过不支持的文件类型")
-				}
+			if srcFilePath, _, ok := acceptor.Accept(rawPath, true); ok {
+				srcFilePaths = append(srcFilePaths, srcFilePath)
 			}
 		}
 	}
@@ -151,12 +238,230 @@ func (executor *Executor) processFile(srcFilePath string) error {
 	if !info.Mode().IsRegular() {
 		return fmt.Errorf("源文件为非常规文件")
 	}
+	// JPEG/PNG/WebP 优先使用进程内实现, 其余格式回退到 exiftool
+	format, err := imgfmt.Sniff(srcFilePath)
+	if err != nil {
+		return fmt.Errorf("无法读取源文件\n%w", err)
+	}
+	stripper := metadata.LookupStripper(format)
+	useStripper := stripper != nil && executor.isFullStrip() && !executor.UseExiftool
+	if !useStripper && !executor.hasExiftool {
+		return fmt.Errorf("未找到 exiftool, 无法处理该格式")
+	}
+	// 先写入同目录临时文件, 校验并落盘后再原子替换源文件
+	if executor.Verbose {
+		executor.logInProgress(srcFilePath, "创建临时文件")
+	}
+	tmpFile, err := os.CreateTemp(filepath.Dir(srcFilePath), "noexif-*"+filepath.Ext(srcFilePath))
+	if err != nil {
+		return fmt.Errorf("无法创建临时文件\n%w", err)
+	}
+	defer func() {
+		_ = tmpFile.Close()
+		_ = os.Remove(tmpFile.Name())
+	}()
+	if useStripper {
+		changed, err := executor.stripInProcess(srcFilePath, tmpFile, stripper)
+		if err != nil {
+			return err
+		}
+		if !changed {
+			if executor.Verbose {
+				executor.logSuccess(srcFilePath, "无可移除的元数据")
+			}
+			return nil
+		}
+	} else {
+		if err := executor.stripWithExiftool(srcFilePath, tmpFile); err != nil {
+			return err
+		}
+	}
+	if err := executor.replaceSource(srcFilePath, info, tmpFile); err != nil {
+		return err
+	}
+	if executor.Verbose {
+		executor.logSuccess(srcFilePath, "元数据已移除")
+	}
+	return nil
+}
 
+func (executor *Executor) stripInProcess(srcFilePath string, tmpFile *os.File, stripper metadata.Stripper) (bool, error) {
+	if executor.Verbose {
+		executor.logInProgress(srcFilePath, "读取源文件")
+	}
+	data, err := os.ReadFile(srcFilePath)
+	if err != nil {
+		return false, fmt.Errorf("无法读取源文件\n%w", err)
+	}
+	if executor.Verbose {
+		executor.logInProgress(srcFilePath, "移除元数据")
+	}
+	stripped, err := stripper(data)
+	if err != nil {
+		return false, fmt.Errorf("无法解析源文件\n%w", err)
+	}
+	if bytes.Equal(stripped, data) {
+		return false, nil
+	}
+	if _, err := tmpFile.Write(stripped); err != nil {
+		return false, fmt.Errorf("无法写入临时文件\n%w", err)
+	}
+	return true, nil
+}
+
+func (executor *Executor) stripWithExiftool(srcFilePath string, tmpFile *os.File) error {
 	if executor.Verbose {
 		executor.logInProgress(srcFilePath, "执行 exiftool 命令")
 	}
+	// -o - 将结果写到标准输出, 由临时文件接收
+	args := append([]string{}, executor.exiftoolArgs...)
+	args = append(args, "-m", "-o", "-", "--", srcFilePath)
+	cmd := exec.Command("exiftool", args...)
+	var cmdErr bytes.Buffer
+	cmd.Stdout = tmpFile
+	cmd.Stderr = &cmdErr
+	if err := cmd.Run(); err != nil {
+		cmdErrMsg := cmdErr.String()
+		if !strings.HasSuffix(cmdErrMsg, "\n") {
+			cmdErrMsg += "\n"
+		}
+		executor.logger.PrintfErr(logging.LogModeAppend, false, "%s", cmdErrMsg)
+		return fmt.Errorf("执行 exiftool 命令失败\n%w", err)
+	}
+	return nil
+}
+
+// replaceSource 校验临时文件, fsync 后原子替换源文件, 必要时先备份原文件
+func (executor *Executor) replaceSource(srcFilePath string, info os.FileInfo, tmpFile *os.File) error {
+	if executor.Verbose {
+		executor.logInProgress(srcFilePath, "校验输出文件")
+	}
+	if err := validateOutput(srcFilePath, tmpFile); err != nil {
+		return fmt.Errorf("输出文件校验失败\n%w", err)
+	}
+	if err := tmpFile.Chmod(info.Mode().Perm()); err != nil {
+		return fmt.Errorf("无法设置临时文件权限\n%w", err)
+	}
+	if err := tmpFile.Sync(); err != nil {
+		return fmt.Errorf("无法同步临时文件\n%w", err)
+	}
+	if err := tmpFile.Close(); err != nil {
+		return fmt.Errorf("无法关闭临时文件\n%w", err)
+	}
+	if executor.BackupDir != "" {
+		if executor.Verbose {
+			executor.logInProgress(srcFilePath, "备份源文件")
+		}
+		if err := executor.backupSource(srcFilePath); err != nil {
+			return fmt.Errorf("无法备份源文件\n%w", err)
+		}
+	}
+	if err := os.Rename(tmpFile.Name(), srcFilePath); err != nil {
+		return fmt.Errorf("无法替换源文件\n%w", err)
+	}
+	// 同步目录项, 保证重命名落盘
+	if dir, err := os.Open(filepath.Dir(srcFilePath)); err == nil {
+		_ = dir.Sync()
+		_ = dir.Close()
+	}
+	return nil
+}
+
+// backupSource 将源文件硬链接到备份目录, 跨文件系统时回退为复制
+func (executor *Executor) backupSource(srcFilePath string) error {
+	absPath, err := filepath.Abs(srcFilePath)
+	if err != nil {
+		return err
+	}
+	// 备份目录内保留相对当前目录的路径结构, 目录外的文件使用绝对路径
+	relPath := absPath
+	if cwd, err := os.Getwd(); err == nil {
+		if rel, err := filepath.Rel(cwd, absPath); err == nil && !strings.HasPrefix(rel, "..") {
+			relPath = rel
+		}
+	}
+	backupPath := filepath.Join(executor.BackupDir, strings.TrimPrefix(relPath, string(filepath.Separator)))
+	if _, err := os.Lstat(backupPath); err == nil {
+		return fmt.Errorf("备份文件已存在: %s", backupPath)
+	}
+	if err := os.MkdirAll(filepath.Dir(backupPath), 0o755); err != nil {
+		return err
+	}
+	if err := os.Link(srcFilePath, backupPath); err == nil {
+		return nil
+	}
+	srcFile, err := os.Open(srcFilePath)
+	if err != nil {
+		return err
+	}
+	defer func(file *os.File) {
+		_ = file.Close()
+	}(srcFile)
+	backupFile, err := os.OpenFile(backupPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(backupFile, srcFile); err != nil {
+		_ = backupFile.Close()
+		_ = os.Remove(backupPath)
+		return err
+	}
+	if err := backupFile.Sync(); err != nil {
+		_ = backupFile.Close()
+		return err
+	}
+	return backupFile.Close()
+}
+
+// validateOutput 确认输出非空且与源文件为同一图像格式
+func validateOutput(srcFilePath string, tmpFile *os.File) error {
+	tmpInfo, err := tmpFile.Stat()
+	if err != nil {
+		return err
+	}
+	if tmpInfo.Size() == 0 {
+		return fmt.Errorf("输出文件为空")
+	}
+	// BMP 需要 14 字节文件头才能识别
+	tmpHeader := make([]byte, 14)
+	n, err := tmpFile.ReadAt(tmpHeader, 0)
+	if err != nil && err != io.EOF {
+		return err
+	}
+	srcFormat, err := imgfmt.Sniff(srcFilePath)
+	if err != nil {
+		return err
+	}
+	tmpFormat := imgfmt.Detect(tmpHeader[:n])
+	if srcFormat == imgfmt.Unknown || srcFormat != tmpFormat {
+		return fmt.Errorf("输出文件格式与源文件不一致")
+	}
+	return nil
+}
 
-	cmd := exec.Command("exiftool", "-all=", "-overwrite_original", "-m", srcFilePath)
+// previewFile 将处理结果写入临时目录, 对比前后标签列出将被移除的内容, 不修改源文件
+func (executor *Executor) previewFile(srcFilePath string) error {
+	info, err := os.Lstat(srcFilePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("源文件不存在")
+		}
+		return fmt.Errorf("无法获取源文件状态\n%w", err)
+	}
+	if info.Mode()&os.ModeSymlink != 0 || !info.Mode().IsRegular() {
+		return nil
+	}
+	tmpDirPath, err := os.MkdirTemp("", "noexif-preview-*")
+	if err != nil {
+		return fmt.Errorf("无法创建临时目录\n%w", err)
+	}
+	defer func() {
+		_ = os.RemoveAll(tmpDirPath)
+	}()
+	previewFilePath := filepath.Join(tmpDirPath, filepath.Base(srcFilePath))
+	args := append([]string{}, executor.exiftoolArgs...)
+	args = append(args, "-m", "-o", previewFilePath, "--", srcFilePath)
+	cmd := exec.Command("exiftool", args...)
 	var cmdErr bytes.Buffer
 	cmd.Stdout = io.Discard
 	cmd.Stderr = &cmdErr
@@ -168,8 +473,34 @@ func (executor *Executor) processFile(srcFilePath string) error {
 		executor.logger.PrintfErr(logging.LogModeAppend, false, "%s", cmdErrMsg)
 		return fmt.Errorf("执行 exiftool 命令失败\n%w", err)
 	}
-	if executor.Verbose {
-		executor.logSuccess(srcFilePath, "元数据已移除")
+	results, err := metadata.ReadTags(srcFilePath, previewFilePath)
+	if err != nil {
+		return err
+	}
+	if len(results) != 2 {
+		return fmt.Errorf("无法读取元数据")
+	}
+	remaining := make(map[string]bool)
+	for _, tag := range results[1].Tags {
+		remaining[tag.Key()] = true
+	}
+	var removedTags []metadata.Tag
+	for _, tag := range results[0].Tags {
+		if !remaining[tag.Key()] {
+			removedTags = append(removedTags, tag)
+		}
+	}
+	if len(removedTags) == 0 {
+		executor.logSuccess(srcFilePath, "无可移除的元数据")
+		return nil
+	}
+	executor.logSuccess(srcFilePath, fmt.Sprintf("将移除 %d 个标签", len(removedTags)))
+	for _, tag := range removedTags {
+		value := tag.Value
+		if len([]rune(value)) > 60 {
+			value = string([]rune(value)[:60]) + "..."
+		}
+		executor.logger.PrintfOut(logging.LogModeAppend, true, "    [%s:%s] %s = %s", tag.Group0, tag.Group1, tag.Name, value)
 	}
 	return nil
 }
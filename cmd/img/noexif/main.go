@@ -1,7 +1,8 @@
 package noexif
 
 import (
-	"trance-cli/internal/system"
+	"strings"
+	"trance-cli/internal/metadata"
 
 	"github.com/spf13/cobra"
 )
@@ -10,9 +11,11 @@ var executor = &Executor{}
 
 var cmd = &cobra.Command{
 	Use:   "noexif <file1> [<file2> ...]",
-	Short: "Remove all EXIF data from images using exiftool",
-	Long:  "",
-	Args:  cobra.MinimumNArgs(1),
+	Short: "Remove EXIF data from images, falling back to exiftool for uncommon formats",
+	Long: "A full strip of JPEG, PNG and WebP files runs in-process unless --exiftool is given. It removes EXIF, XMP, IPTC, comments, " +
+		"text chunks and data after the end of the image such as embedded preview images, but keeps the ICC color profile " +
+		"and the JFIF and Adobe segments needed to decode JPEG colors. Use --exiftool to remove the ICC profile as well.",
+	Args: cobra.MinimumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		executor.Run(cmd, args)
 	},
@@ -24,7 +27,13 @@ var cmd = &cobra.Command{
 func Register(parentCmd *cobra.Command) {
 	cmd.Flags().BoolVarP(&executor.Verbose, "verbose", "v", false, "verbosely list files processed")
 	cmd.Flags().BoolVarP(&executor.Recursive, "recursion", "r", false, "recurse into directories")
-	if system.IsCommandAvailable("exiftool") {
-		parentCmd.AddCommand(cmd)
-	}
+	cmd.Flags().BoolVar(&executor.FixExt, "fix-ext", false, "rename files whose extension does not match their content (ignored with --preview)")
+	categories := strings.Join(metadata.CategoryNames(), ",")
+	cmd.Flags().StringSliceVarP(&executor.Keep, "keep", "k", nil, "strip everything except these categories ("+categories+")")
+	cmd.Flags().StringSliceVarP(&executor.Strip, "strip", "s", nil, "strip only these categories ("+categories+")")
+	cmd.Flags().BoolVar(&executor.GPSOnly, "gps-only", false, "strip GPS location data only")
+	cmd.Flags().BoolVar(&executor.Preview, "preview", false, "list the tags that would be removed without modifying files")
+	cmd.Flags().BoolVar(&executor.UseExiftool, "exiftool", false, "always use exiftool instead of the built-in JPEG/PNG/WebP stripper, which keeps ICC profiles")
+	cmd.Flags().StringVarP(&executor.BackupDir, "backup-dir", "b", "", "keep the original files under this directory before replacing them")
+	parentCmd.AddCommand(cmd)
 }
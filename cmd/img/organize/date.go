@@ -0,0 +1,57 @@
+package organize
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+	"trance-cli/internal/metadata"
+)
+
+type dateSource string
+
+const (
+	dateSourceEXIF     dateSource = "exif"
+	dateSourceFilename dateSource = "filename"
+	dateSourceMtime    dateSource = "mtime"
+)
+
+var (
+	// IMG_20240503_142233.jpg, PXL_20240503_142233123.jpg, Screenshot 2024-05-03 at 14.22.33.png
+	filenameDateTimePattern = regexp.MustCompile(`(?:^|\D)((?:19|20)\d{2})[-_.]?(\d{2})[-_.]?(\d{2})(?:[-_ T]|\sat\s)(\d{2})[-_.:h]?(\d{2})[-_.:m]?(\d{2})`)
+	// IMG-20240503-WA0001.jpg, 2024-05-03.png
+	filenameDatePattern = regexp.MustCompile(`(?:^|\D)((?:19|20)\d{2})[-_.]?(\d{2})[-_.]?(\d{2})(?:\D|$)`)
+)
+
+// captureDate 依次尝试 EXIF 拍摄时间、文件名中的日期与修改时间
+func captureDate(srcFilePath string, modTime time.Time) (time.Time, dateSource) {
+	if data, err := os.ReadFile(srcFilePath); err == nil {
+		if rawEXIF := metadata.ExtractEXIF(data); rawEXIF != nil {
+			if exif, err := metadata.ParseEXIF(rawEXIF); err == nil {
+				if dateTime, ok := exif.DateTimeOriginal(); ok {
+					return dateTime, dateSourceEXIF
+				}
+			}
+		}
+	}
+	if dateTime, ok := parseFilenameDate(filepath.Base(srcFilePath)); ok {
+		return dateTime, dateSourceFilename
+	}
+	return modTime, dateSourceMtime
+}
+
+func parseFilenameDate(baseName string) (time.Time, bool) {
+	if match := filenameDateTimePattern.FindStringSubmatch(baseName); match != nil {
+		value := match[1] + match[2] + match[3] + match[4] + match[5] + match[6]
+		if dateTime, err := time.ParseInLocation("20060102150405", value, time.Local); err == nil {
+			return dateTime, true
+		}
+	}
+	// 只含日期时取当天零点
+	for _, match := range filenameDatePattern.FindAllStringSubmatch(baseName, -1) {
+		if date, err := time.ParseInLocation("20060102", match[1]+match[2]+match[3], time.Local); err == nil {
+			return date, true
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,395 @@
+package organize
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+	"trance-cli/internal/imgfmt"
+	"trance-cli/internal/logging"
+
+	"github.com/gookit/color"
+	"github.com/schollz/progressbar/v3"
+	"github.com/spf13/cobra"
+)
+
+// 按文件内容识别的可处理格式
+var supportedFormats = imgfmt.AllFormats
+
+var placeholderPattern = regexp.MustCompile(`\{([a-z]*)\}`)
+
+var placeholders = []string{"year", "month", "day", "date", "time", "hour", "minute", "second", "name", "ext", "seq", "source"}
+
+type Executor struct {
+	logger    logging.Logger
+	Verbose   bool
+	Recursive bool
+	FixExt    bool
+	Pattern   string
+	OutDir    string
+	DryRun    bool
+}
+
+type OrganizeJob struct {
+	SrcPath string
+	// 目标路径模式的根目录
+	Root     string
+	Format   imgfmt.Format
+	Date     time.Time
+	Source   dateSource
+	DestPath string
+}
+
+func (executor *Executor) Run(cmd *cobra.Command, rawPaths []string) {
+	executor.logger = logging.Logger{
+		OutWriter: cmd.OutOrStdout(),
+		ErrWriter: cmd.ErrOrStderr(),
+		State:     logging.LoggerStateNewLine,
+	}
+	if err := executor.validatePattern(); err != nil {
+		executor.logger.PrintfErr(logging.LogModeAppend, true, "%v", err)
+		os.Exit(1)
+	}
+
+	jobs := executor.collectJobs(rawPaths)
+	if len(jobs) == 0 {
+		return
+	}
+	var hadError bool
+	bar := progressbar.NewOptions(len(jobs),
+		progressbar.OptionSetWriter(cmd.OutOrStdout()),
+		progressbar.OptionShowCount(),
+		progressbar.OptionShowIts(),
+		progressbar.OptionSpinnerType(14),
+		progressbar.OptionSetRenderBlankState(!executor.Verbose),
+		progressbar.OptionSetVisibility(!executor.Verbose),
+		progressbar.OptionSetTheme(progressbar.Theme{
+			Saucer:        "=",
+			SaucerHead:    ">",
+			SaucerPadding: " ",
+			BarStart:      "[",
+			BarEnd:        "]",
+		}),
+	)
+	if !executor.Verbose {
+		executor.logger.SetState(logging.LoggerStateOutOldLine)
+	}
+	var datedJobs []*OrganizeJob
+	for _, job := range jobs {
+		if ok, err := executor.readDate(job); err != nil {
+			executor.logError(job.SrcPath, err.Error())
+			hadError = true
+		} else if ok {
+			datedJobs = append(datedJobs, job)
+		}
+		if !executor.Verbose {
+			_ = bar.Add(1)
+			executor.logger.SetState(logging.LoggerStateOutOldLine)
+		}
+	}
+
+	// 按拍摄时间排序, 使 {seq} 与拍摄顺序一致
+	sort.SliceStable(datedJobs, func(i, j int) bool {
+		if !datedJobs[i].Date.Equal(datedJobs[j].Date) {
+			return datedJobs[i].Date.Before(datedJobs[j].Date)
+		}
+		return datedJobs[i].SrcPath < datedJobs[j].SrcPath
+	})
+	reserved := make(map[string]bool)
+	var moveCount, inPlaceCount int
+	for _, job := range datedJobs {
+		if err := executor.planJob(job, reserved); err != nil {
+			executor.logError(job.SrcPath, err.Error())
+			hadError = true
+			continue
+		}
+		if job.DestPath == "" {
+			inPlaceCount++
+			if executor.Verbose {
+				executor.logSuccess(job.SrcPath, "已在目标位置")
+			}
+			continue
+		}
+		if executor.DryRun {
+			executor.logger.PrintfOut(logging.LogModeAppend, true, "%s -> %s (%s)", job.SrcPath, job.DestPath, job.Source)
+			moveCount++
+			continue
+		}
+		if executor.Verbose {
+			executor.logInProgress(job.SrcPath, "移动文件")
+		}
+		if err := moveFile(job.SrcPath, job.DestPath); err != nil {
+			executor.logError(job.SrcPath, fmt.Sprintf("无法移动文件\n%v", err))
+			hadError = true
+			continue
+		}
+		moveCount++
+		if executor.Verbose {
+			executor.logSuccess(job.SrcPath, fmt.Sprintf("已移动至 %s (%s)", job.DestPath, job.Source))
+		}
+	}
+	if executor.DryRun {
+		executor.logger.PrintfOut(logging.LogModeAppend, true, "共计划移动 %d 个文件, %d 个文件已在目标位置", moveCount, inPlaceCount)
+	} else {
+		executor.logger.PrintfOut(logging.LogModeAppend, true, "共移动 %d 个文件, %d 个文件已在目标位置", moveCount, inPlaceCount)
+	}
+	if hadError {
+		os.Exit(1)
+	}
+}
+
+func (executor *Executor) validatePattern() error {
+	if strings.TrimSpace(executor.Pattern) == "" {
+		return fmt.Errorf("路径模式不能为空")
+	}
+	if filepath.IsAbs(executor.Pattern) {
+		return fmt.Errorf("路径模式需为相对路径: %s", executor.Pattern)
+	}
+	for _, part := range strings.Split(executor.Pattern, "/") {
+		if part == ".." {
+			return fmt.Errorf("路径模式不能包含 ..: %s", executor.Pattern)
+		}
+	}
+	for _, match := range placeholderPattern.FindAllStringSubmatch(executor.Pattern, -1) {
+		if !slices.Contains(placeholders, match[1]) {
+			return fmt.Errorf("未知占位符: %s (可选: {%s})", match[0], strings.Join(placeholders, "}, {"))
+		}
+	}
+	return nil
+}
+
+func (executor *Executor) collectJobs(rawPaths []string) []*OrganizeJob {
+	acceptor := imgfmt.Acceptor{
+		Logger:    &executor.logger,
+		Supported: supportedFormats,
+		Verbose:   executor.Verbose,
+		// dry-run 模式不修改文件, 只报告扩展名不一致
+		FixExt: executor.FixExt && !executor.DryRun,
+	}
+	var jobs []*OrganizeJob
+	for _, rawPath := range rawPaths {
+		info, err := os.Stat(rawPath)
+		if err != nil {
+			if os.IsNotExist(err) {
+				executor.logError(rawPath, "文件或目录不存在")
+				continue
+			} else {
+				executor.logError(rawPath, fmt.Sprintf("无法获取文件或目录状态\n%v", err))
+				continue
+			}
+		}
+		if info.IsDir() {
+			if executor.Recursive {
+				if executor.Verbose {
+					executor.logInProgress(rawPath, "递归搜索目录")
+				}
+				root := rawPath
+				if executor.OutDir != "" {
+					root = executor.OutDir
+				}
+				err := filepath.WalkDir(rawPath, func(currentPath string, entry fs.DirEntry, err error) error {
+					if err != nil {
+						return err
+					}
+					if !entry.IsDir() {
+						if srcFilePath, format, ok := acceptor.Accept(currentPath, false); ok {
+							jobs = append(jobs, &OrganizeJob{SrcPath: srcFilePath, Root: root, Format: format})
+						}
+					}
+					return nil
+				})
+				if err != nil {
+					executor.logError(rawPath, fmt.Sprintf("遍历目录失败\n%v", err))
+				}
+				if executor.Verbose {
+					executor.logSuccess(rawPath, "递归搜索目录完成")
+				}
+			} else {
+				executor.logError(rawPath, "跳过目录")
+			}
+		} else {
+			root := filepath.Dir(rawPath)
+			if executor.OutDir != "" {
+				root = executor.OutDir
+			}
+			if srcFilePath, format, ok := acceptor.Accept(rawPath, true); ok {
+				jobs = append(jobs, &OrganizeJob{SrcPath: srcFilePath, Root: root, Format: format})
+			}
+		}
+	}
+	return jobs
+}
+
+// readDate 读取拍摄时间, 与其他命令一样跳过符号链接, 跳过时返回 false
+func (executor *Executor) readDate(job *OrganizeJob) (bool, error) {
+	if executor.Verbose {
+		executor.logInProgress(job.SrcPath, "读取拍摄时间")
+	}
+	info, err := os.Lstat(job.SrcPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, fmt.Errorf("源文件不存在")
+		}
+		return false, fmt.Errorf("无法获取源文件状态\n%w", err)
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		if executor.Verbose {
+			executor.logSuccess(job.SrcPath, "跳过符号链接")
+		}
+		return false, nil
+	}
+	if !info.Mode().IsRegular() {
+		return false, fmt.Errorf("源文件为非常规文件")
+	}
+	job.Date, job.Source = captureDate(job.SrcPath, info.ModTime())
+	return true, nil
+}
+
+// planJob 计算目标路径并预留, 源文件已在目标位置时 DestPath 为空
+func (executor *Executor) planJob(job *OrganizeJob, reserved map[string]bool) error {
+	absSrcPath, err := filepath.Abs(job.SrcPath)
+	if err != nil {
+		return err
+	}
+	hasSeq := strings.Contains(executor.Pattern, "{seq}")
+	for seq := 1; ; seq++ {
+		relPath := executor.renderPattern(job, seq)
+		// 模式不含 {seq} 时在扩展名前追加序号避免冲突
+		if !hasSeq && seq > 1 {
+			ext := filepath.Ext(relPath)
+			relPath = strings.TrimSuffix(relPath, ext) + fmt.Sprintf("_%d", seq) + ext
+		}
+		destPath := filepath.Join(job.Root, relPath)
+		absDestPath, err := filepath.Abs(destPath)
+		if err != nil {
+			return err
+		}
+		if absDestPath == absSrcPath {
+			job.DestPath = ""
+			return nil
+		}
+		if reserved[absDestPath] {
+			continue
+		}
+		if _, err := os.Lstat(absDestPath); err == nil {
+			continue
+		} else if !os.IsNotExist(err) {
+			return fmt.Errorf("无法获取目标文件状态\n%w", err)
+		}
+		reserved[absDestPath] = true
+		job.DestPath = destPath
+		return nil
+	}
+}
+
+func (executor *Executor) renderPattern(job *OrganizeJob, seq int) string {
+	baseName := filepath.Base(job.SrcPath)
+	date := job.Date
+	return placeholderPattern.ReplaceAllStringFunc(executor.Pattern, func(placeholder string) string {
+		switch strings.Trim(placeholder, "{}") {
+		case "year":
+			return date.Format("2006")
+		case "month":
+			return date.Format("01")
+		case "day":
+			return date.Format("02")
+		case "date":
+			return date.Format("20060102")
+		case "time":
+			return date.Format("150405")
+		case "hour":
+			return date.Format("15")
+		case "minute":
+			return date.Format("04")
+		case "second":
+			return date.Format("05")
+		case "name":
+			return strings.TrimSuffix(baseName, filepath.Ext(baseName))
+		case "ext":
+			return strings.TrimPrefix(job.Format.Ext(), ".")
+		case "seq":
+			return fmt.Sprintf("%03d", seq)
+		case "source":
+			return string(job.Source)
+		}
+		return placeholder
+	})
+}
+
+// moveFile 以硬链接方式移动, 不覆盖已存在的目标文件, 跨文件系统时复制后删除源文件
+func moveFile(srcFilePath string, destFilePath string) error {
+	if err := os.MkdirAll(filepath.Dir(destFilePath), 0o755); err != nil {
+		return err
+	}
+	err := os.Link(srcFilePath, destFilePath)
+	switch {
+	case err == nil:
+		return os.Remove(srcFilePath)
+	case errors.Is(err, fs.ErrExist):
+		return fmt.Errorf("目标文件已存在: %s", destFilePath)
+	case errors.Is(err, syscall.EXDEV):
+		return copyFile(srcFilePath, destFilePath)
+	}
+	// 不支持硬链接的文件系统
+	if _, err := os.Lstat(destFilePath); err == nil {
+		return fmt.Errorf("目标文件已存在: %s", destFilePath)
+	}
+	return os.Rename(srcFilePath, destFilePath)
+}
+
+func copyFile(srcFilePath string, destFilePath string) error {
+	srcFile, err := os.Open(srcFilePath)
+	if err != nil {
+		return err
+	}
+	defer func(file *os.File) {
+		_ = file.Close()
+	}(srcFile)
+	info, err := srcFile.Stat()
+	if err != nil {
+		return err
+	}
+	destFile, err := os.OpenFile(destFilePath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(destFile, srcFile); err != nil {
+		_ = destFile.Close()
+		_ = os.Remove(destFilePath)
+		return err
+	}
+	if err := destFile.Sync(); err != nil {
+		_ = destFile.Close()
+		_ = os.Remove(destFilePath)
+		return err
+	}
+	if err := destFile.Close(); err != nil {
+		_ = os.Remove(destFilePath)
+		return err
+	}
+	_ = os.Chtimes(destFilePath, info.ModTime(), info.ModTime())
+	return os.Remove(srcFilePath)
+}
+
+func (executor *Executor) logInProgress(path string, message string) {
+	inProgressColor := color.New(color.FgCyan, color.Bold)
+	executor.logger.PrintfOut(logging.LogModeInPlace, false, "%s %s: %s", inProgressColor.Sprintf("[>]"), path, message)
+}
+
+func (executor *Executor) logSuccess(path string, message string) {
+	successColor := color.New(color.FgGreen, color.Bold)
+	executor.logger.PrintfOut(logging.LogModeInPlace, true, "%s %s: %s", successColor.Sprintf("[O]"), path, message)
+}
+
+func (executor *Executor) logError(path string, message string) {
+	errorColor := color.New(color.FgRed, color.Bold)
+	executor.logger.PrintfErr(logging.LogModeAppend, true, "%s %s: %s", errorColor.Sprintf("[X]"), path, message)
+}
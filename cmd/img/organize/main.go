@@ -0,0 +1,32 @@
+package organize
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var executor = &Executor{}
+
+var cmd = &cobra.Command{
+	Use:   "organize <file1|dir1> [<file2|dir2> ...]",
+	Short: "Move images into folders and file names derived from their capture date",
+	Long: "Capture dates are taken from EXIF DateTimeOriginal, then from dates embedded in the file name, then from the modification time.\n\n" +
+		"Pattern placeholders: {year} {month} {day} {date} (YYYYMMDD) {time} (HHMMSS) {hour} {minute} {second} {name} (original name without extension) {ext} {seq} {source} (exif, filename, mtime).\n" +
+		"{seq} is the smallest number that makes the target unique; without it, colliding files get a _2, _3, ... suffix.",
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		executor.Run(cmd, args)
+	},
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return []string{"jpg", "jpeg", "png", "bmp", "tiff", "gif", "webp", "jxl"}, cobra.ShellCompDirectiveFilterFileExt
+	},
+}
+
+func Register(parentCmd *cobra.Command) {
+	cmd.Flags().BoolVarP(&executor.Verbose, "verbose", "v", false, "verbosely list files processed")
+	cmd.Flags().BoolVarP(&executor.Recursive, "recursion", "r", false, "recurse into directories")
+	cmd.Flags().BoolVar(&executor.FixExt, "fix-ext", false, "rename files whose extension does not match their content (ignored with --dry-run)")
+	cmd.Flags().StringVarP(&executor.Pattern, "pattern", "p", "{year}/{month}/{date}_{time}_{seq}.{ext}", "target path pattern relative to the output directory")
+	cmd.Flags().StringVarP(&executor.OutDir, "out-dir", "o", "", "output directory (default: each input directory, or the parent directory of input files)")
+	cmd.Flags().BoolVarP(&executor.DryRun, "dry-run", "n", false, "print the planned moves without moving any file")
+	parentCmd.AddCommand(cmd)
+}
@@ -0,0 +1,398 @@
+package resize
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"trance-cli/internal/imgfmt"
+	"trance-cli/internal/logging"
+	"trance-cli/internal/metadata"
+
+	"github.com/gookit/color"
+	"github.com/schollz/progressbar/v3"
+	"github.com/spf13/cobra"
+	"golang.org/x/image/bmp"
+	"golang.org/x/image/draw"
+	"golang.org/x/image/tiff"
+	_ "golang.org/x/image/webp"
+)
+
+// 按文件内容识别的可处理格式, JXL 无解码器
+var supportedFormats = []imgfmt.Format{imgfmt.JPEG, imgfmt.PNG, imgfmt.BMP, imgfmt.TIFF, imgfmt.GIF, imgfmt.WebP}
+
+type Executor struct {
+	logger            logging.Logger
+	Verbose           bool
+	Recursive         bool
+	FixExt            bool
+	MaxDimension      int
+	Percent           int
+	Width             int
+	Height            int
+	Mode              string
+	Upscale           bool
+	OutDir            string
+	Suffix            string
+	Quality           int
+	IgnoreOrientation bool
+	skipCount         int
+}
+
+type ResizeJob struct {
+	SrcPath  string
+	DestPath string
+}
+
+// skipError 表示文件被有意跳过, 不计为失败
+type skipError struct {
+	reason string
+}
+
+func (err *skipError) Error() string {
+	return err.reason
+}
+
+func (executor *Executor) Run(cmd *cobra.Command, rawPaths []string) {
+	executor.logger = logging.Logger{
+		OutWriter: cmd.OutOrStdout(),
+		ErrWriter: cmd.ErrOrStderr(),
+		State:     logging.LoggerStateNewLine,
+	}
+	if err := executor.validateOptions(); err != nil {
+		executor.logger.PrintfErr(logging.LogModeAppend, true, "%s", err.Error())
+		os.Exit(1)
+	}
+
+	jobs, _ := executor.collectResizeJobs(rawPaths)
+	if len(jobs) == 0 {
+		return
+	}
+	var hadError bool
+	if executor.Verbose {
+		for _, job := range jobs {
+			err := executor.processResizeJob(job)
+			var skipErr *skipError
+			if errors.As(err, &skipErr) {
+				executor.logSkip(job.SrcPath, skipErr.reason)
+			} else if err != nil {
+				executor.logError(job.SrcPath, err.Error())
+				hadError = true
+			}
+		}
+	} else {
+		bar := progressbar.NewOptions(len(jobs),
+			progressbar.OptionSetWriter(cmd.OutOrStdout()),
+			progressbar.OptionShowCount(),
+			progressbar.OptionShowIts(),
+			progressbar.OptionSpinnerType(14),
+			progressbar.OptionSetRenderBlankState(true),
+			progressbar.OptionSetTheme(progressbar.Theme{
+				Saucer:        "=",
+				SaucerHead:    ">",
+				SaucerPadding: " ",
+				BarStart:      "[",
+				BarEnd:        "]",
+			}),
+		)
+		executor.logger.SetState(logging.LoggerStateOutOldLine)
+		for _, job := range jobs {
+			err := executor.processResizeJob(job)
+			var skipErr *skipError
+			if errors.As(err, &skipErr) {
+				executor.logSkip(job.SrcPath, skipErr.reason)
+				_ = bar.Add(1)
+				executor.logger.SetState(logging.LoggerStateOutOldLine)
+			} else if err != nil {
+				executor.logError(job.SrcPath, err.Error())
+				hadError = true
+			} else {
+				_ = bar.Add(1)
+				executor.logger.SetState(logging.LoggerStateOutOldLine)
+			}
+		}
+		executor.logger.PrintfOut(logging.LogModeAppend, false, "")
+	}
+	if executor.skipCount > 0 {
+		executor.logger.PrintfOut(logging.LogModeAppend, true, "共跳过 %d 个文件", executor.skipCount)
+	}
+	if hadError {
+		os.Exit(1)
+	}
+}
+
+func (executor *Executor) validateOptions() error {
+	// 先校验取值范围, 负数不计为已指定的缩放方式, 否则会得到缩放方式数量错误的提示
+	if executor.MaxDimension < 0 || executor.Percent < 0 || executor.Width < 0 || executor.Height < 0 {
+		return fmt.Errorf("尺寸参数不能为负数")
+	}
+	if executor.Quality < 1 || executor.Quality > 100 {
+		return fmt.Errorf("JPEG 质量需在 1-100 之间")
+	}
+	modeCount := 0
+	if executor.MaxDimension > 0 {
+		modeCount++
+	}
+	if executor.Percent > 0 {
+		modeCount++
+	}
+	if executor.Width > 0 || executor.Height > 0 {
+		modeCount++
+	}
+	if modeCount != 1 {
+		return fmt.Errorf("需要且只能指定 --max, --percent 或 --width/--height 之一")
+	}
+	switch executor.Mode {
+	case "fit":
+	case "fill":
+		if (executor.Width > 0 || executor.Height > 0) && (executor.Width == 0 || executor.Height == 0) {
+			return fmt.Errorf("fill 模式需要同时指定 --width 与 --height")
+		}
+	default:
+		return fmt.Errorf("未知缩放模式: %s (可选: fit, fill)", executor.Mode)
+	}
+	if executor.OutDir == "" && executor.Suffix == "" {
+		return fmt.Errorf("未指定输出目录时后缀不能为空, 否则将覆盖源文件")
+	}
+	return nil
+}
+
+// destPath 计算输出路径, 输出格式与源文件内容一致, WebP 无编码器时输出 PNG
+func (executor *Executor) destPath(srcFilePath string, format imgfmt.Format, destDir string) string {
+	baseName := filepath.Base(srcFilePath)
+	ext := filepath.Ext(baseName)
+	destExt := ext
+	if !format.MatchesExt(srcFilePath) {
+		destExt = format.Ext()
+	}
+	if format == imgfmt.WebP {
+		destExt = imgfmt.PNG.Ext()
+	}
+	return filepath.Join(destDir, strings.TrimSuffix(baseName, ext)+executor.Suffix+destExt)
+}
+
+func (executor *Executor) collectResizeJobs(rawPaths []string) ([]ResizeJob, error) {
+	acceptor := imgfmt.Acceptor{
+		Logger:    &executor.logger,
+		Supported: supportedFormats,
+		Verbose:   executor.Verbose,
+		FixExt:    executor.FixExt,
+	}
+	var jobs []ResizeJob
+	var absOutDir string
+	if executor.OutDir != "" {
+		absOutDir, _ = filepath.Abs(executor.OutDir)
+	}
+	for _, rawPath := range rawPaths {
+		info, err := os.Stat(rawPath)
+		if err != nil {
+			if os.IsNotExist(err) {
+				executor.logError(rawPath, "文件或目录不存在")
+				continue
+			} else {
+				executor.logError(rawPath, fmt.Sprintf("无法获取文件或目录状态\n%v", err))
+				continue
+			}
+		}
+		if info.IsDir() {
+			if executor.Recursive {
+				if executor.Verbose {
+					executor.logInProgress(rawPath, "递归搜索目录")
+				}
+				err := filepath.WalkDir(rawPath, func(currentPath string, entry fs.DirEntry, err error) error {
+					if err != nil {
+						return err
+					}
+					if entry.IsDir() {
+						// 跳过位于源目录内的输出目录
+						if absOutDir != "" {
+							if absPath, err := filepath.Abs(currentPath); err == nil && absPath == absOutDir {
+								return filepath.SkipDir
+							}
+						}
+						return nil
+					}
+					// 跳过上次运行生成的文件
+					baseName := filepath.Base(currentPath)
+					if executor.Suffix != "" && strings.HasSuffix(strings.TrimSuffix(baseName, filepath.Ext(baseName)), executor.Suffix) {
+						return nil
+					}
+					srcFilePath, format, ok := acceptor.Accept(currentPath, false)
+					if !ok {
+						return nil
+					}
+					destDir := filepath.Dir(currentPath)
+					if executor.OutDir != "" {
+						relDir, err := filepath.Rel(rawPath, filepath.Dir(currentPath))
+						if err != nil {
+							return err
+						}
+						destDir = filepath.Join(executor.OutDir, relDir)
+					}
+					jobs = append(jobs, ResizeJob{
+						SrcPath:  srcFilePath,
+						DestPath: executor.destPath(srcFilePath, format, destDir),
+					})
+					return nil
+				})
+				if err != nil {
+					executor.logError(rawPath, fmt.Sprintf("遍历目录失败\n%v", err))
+				}
+				if executor.Verbose {
+					executor.logSuccess(rawPath, "递归搜索目录完成")
+				}
+			} else {
+				executor.logError(rawPath, "跳过目录")
+			}
+		} else {
+			srcFilePath, format, ok := acceptor.Accept(rawPath, true)
+			if !ok {
+				continue
+			}
+			destDir := filepath.Dir(rawPath)
+			if executor.OutDir != "" {
+				destDir = executor.OutDir
+			}
+			jobs = append(jobs, ResizeJob{
+				SrcPath:  srcFilePath,
+				DestPath: executor.destPath(srcFilePath, format, destDir),
+			})
+		}
+	}
+	return jobs, nil
+}
+
+func (executor *Executor) processResizeJob(job ResizeJob) error {
+	if executor.Verbose {
+		executor.logInProgress(job.SrcPath, "确认文件状态")
+	}
+	info, err := os.Lstat(job.SrcPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("源文件不存在")
+		}
+		return fmt.Errorf("无法获取源文件状态\n%w", err)
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		if executor.Verbose {
+			executor.logSuccess(job.SrcPath, "跳过符号链接")
+		}
+		return nil
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("源文件为非常规文件")
+	}
+	if _, err := os.Stat(job.DestPath); err == nil {
+		return fmt.Errorf("目标文件已存在: %s", job.DestPath)
+	}
+	if executor.Verbose {
+		executor.logInProgress(job.SrcPath, "解码图像")
+	}
+	data, err := os.ReadFile(job.SrcPath)
+	if err != nil {
+		return fmt.Errorf("无法读取源文件\n%w", err)
+	}
+	if imgfmt.Detect(data) == imgfmt.GIF {
+		animation, err := gif.DecodeAll(bytes.NewReader(data))
+		if err == nil && len(animation.Image) > 1 {
+			return &skipError{reason: "不支持缩放动画 GIF, 跳过"}
+		}
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("无法解码图像\n%w", err)
+	}
+	// 输出不携带 EXIF, 需先按方向值转正
+	if !executor.IgnoreOrientation {
+		if rawEXIF := metadata.ExtractEXIF(data); rawEXIF != nil {
+			if exif, err := metadata.ParseEXIF(rawEXIF); err == nil {
+				img = applyOrientation(img, exif.Orientation())
+			}
+		}
+	}
+	if executor.Verbose {
+		executor.logInProgress(job.SrcPath, "缩放图像")
+	}
+	resized := executor.resizeImage(img)
+	destDir := filepath.Dir(job.DestPath)
+	if err := os.MkdirAll(destDir, 0o755); err != nil {
+		return fmt.Errorf("无法创建输出目录\n%w", err)
+	}
+	if executor.Verbose {
+		executor.logInProgress(job.SrcPath, "创建临时文件")
+	}
+	destExt := filepath.Ext(job.DestPath)
+	tmpFile, err := os.CreateTemp(destDir, "resize-*"+destExt)
+	if err != nil {
+		return fmt.Errorf("无法创建临时文件\n%w", err)
+	}
+	defer func() {
+		_ = tmpFile.Close()
+		_ = os.Remove(tmpFile.Name())
+	}()
+	if executor.Verbose {
+		executor.logInProgress(job.SrcPath, "编码图像")
+	}
+	if err := executor.encodeImage(tmpFile, resized, imgfmt.FromExt(job.DestPath)); err != nil {
+		return fmt.Errorf("无法编码图像\n%w", err)
+	}
+	if err := tmpFile.Sync(); err != nil {
+		return fmt.Errorf("无法同步临时文件\n%w", err)
+	}
+	if err := tmpFile.Close(); err != nil {
+		return fmt.Errorf("无法关闭临时文件\n%w", err)
+	}
+	if err := os.Rename(tmpFile.Name(), job.DestPath); err != nil {
+		return fmt.Errorf("无法写入目标文件\n%w", err)
+	}
+	if executor.Verbose {
+		bounds := resized.Bounds()
+		executor.logSuccess(job.SrcPath, fmt.Sprintf("已缩放为 %dx%d: %s", bounds.Dx(), bounds.Dy(), job.DestPath))
+	}
+	return nil
+}
+
+func (executor *Executor) encodeImage(writer io.Writer, img image.Image, format imgfmt.Format) error {
+	switch format {
+	case imgfmt.JPEG:
+		return jpeg.Encode(writer, img, &jpeg.Options{Quality: executor.Quality})
+	case imgfmt.PNG:
+		return png.Encode(writer, img)
+	case imgfmt.GIF:
+		return gif.Encode(writer, img, &gif.Options{NumColors: 256, Drawer: draw.FloydSteinberg})
+	case imgfmt.BMP:
+		return bmp.Encode(writer, img)
+	case imgfmt.TIFF:
+		return tiff.Encode(writer, img, &tiff.Options{Compression: tiff.Deflate})
+	}
+	return fmt.Errorf("不支持的输出格式: %s", format)
+}
+
+func (executor *Executor) logInProgress(path string, message string) {
+	inProgressColor := color.New(color.FgCyan, color.Bold)
+	executor.logger.PrintfOut(logging.LogModeInPlace, false, "%s %s: %s", inProgressColor.Sprintf("[>]"), path, message)
+}
+
+func (executor *Executor) logSuccess(path string, message string) {
+	successColor := color.New(color.FgGreen, color.Bold)
+	executor.logger.PrintfOut(logging.LogModeInPlace, true, "%s %s: %s", successColor.Sprintf("[O]"), path, message)
+}
+
+func (executor *Executor) logSkip(path string, message string) {
+	executor.skipCount++
+	skipColor := color.New(color.FgYellow, color.Bold)
+	executor.logger.PrintfOut(logging.LogModeAppend, true, "%s %s: %s", skipColor.Sprintf("[-]"), path, message)
+}
+
+func (executor *Executor) logError(path string, message string) {
+	errorColor := color.New(color.FgRed, color.Bold)
+	executor.logger.PrintfErr(logging.LogModeAppend, true, "%s %s: %s", errorColor.Sprintf("[X]"), path, message)
+}
@@ -0,0 +1,37 @@
+package resize
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var executor = &Executor{}
+
+var cmd = &cobra.Command{
+	Use:   "resize <file1|dir1> [<file2|dir2> ...]",
+	Short: "Batch resize images or create thumbnails",
+	Long:  "",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		executor.Run(cmd, args)
+	},
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return []string{"jpg", "jpeg", "png", "bmp", "tiff", "gif", "webp"}, cobra.ShellCompDirectiveFilterFileExt
+	},
+}
+
+func Register(parentCmd *cobra.Command) {
+	cmd.Flags().BoolVarP(&executor.Verbose, "verbose", "v", false, "verbosely list files processed")
+	cmd.Flags().BoolVarP(&executor.Recursive, "recursion", "r", false, "recurse into directories")
+	cmd.Flags().BoolVar(&executor.FixExt, "fix-ext", false, "rename files whose extension does not match their content")
+	cmd.Flags().IntVarP(&executor.MaxDimension, "max", "m", 0, "limit the longest side to this many pixels")
+	cmd.Flags().IntVarP(&executor.Percent, "percent", "p", 0, "scale by this percentage")
+	cmd.Flags().IntVarP(&executor.Width, "width", "W", 0, "target box width")
+	cmd.Flags().IntVarP(&executor.Height, "height", "H", 0, "target box height")
+	cmd.Flags().StringVar(&executor.Mode, "mode", "fit", "how to use the target box (fit, fill)")
+	cmd.Flags().BoolVar(&executor.Upscale, "upscale", false, "allow enlarging images smaller than the target")
+	cmd.Flags().StringVarP(&executor.OutDir, "out-dir", "o", "", "output directory (default: next to the source)")
+	cmd.Flags().StringVarP(&executor.Suffix, "suffix", "s", "_resized", "suffix appended to output file names")
+	cmd.Flags().IntVarP(&executor.Quality, "quality", "q", 90, "JPEG output quality (1-100)")
+	cmd.Flags().BoolVar(&executor.IgnoreOrientation, "ignore-orientation", false, "do not apply the EXIF orientation before resizing")
+	parentCmd.AddCommand(cmd)
+}
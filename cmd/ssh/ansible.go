@@ -0,0 +1,435 @@
+package ssh
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 从 ansible_ssh_common_args 等参数中提取跳板机
+var ansibleProxyJumpPattern = regexp.MustCompile(`(?:-J\s*|ProxyJump=)([^\s'"]+)`)
+
+// 主机名中的范围, 如 web[01:10] 或 db-[a:c], 可带步长 [1:10:2]
+var ansibleHostRangePattern = regexp.MustCompile(`\[([0-9a-zA-Z]+):([0-9a-zA-Z]+)(?::([0-9]+))?]`)
+
+type ansibleGroup struct {
+	hosts    []string
+	vars     map[string]string
+	children []string
+}
+
+// ansibleInventory 为解析后的 Ansible 清单, 主机与组均按出现顺序记录
+type ansibleInventory struct {
+	groups     map[string]*ansibleGroup
+	groupOrder []string
+	hostVars   map[string]map[string]string
+	hostOrder  []string
+}
+
+func newAnsibleInventory() *ansibleInventory {
+	return &ansibleInventory{
+		groups:   make(map[string]*ansibleGroup),
+		hostVars: make(map[string]map[string]string),
+	}
+}
+
+func (inventory *ansibleInventory) group(name string) *ansibleGroup {
+	group, ok := inventory.groups[name]
+	if !ok {
+		group = &ansibleGroup{vars: make(map[string]string)}
+		inventory.groups[name] = group
+		inventory.groupOrder = append(inventory.groupOrder, name)
+	}
+	return group
+}
+
+// addHost 将主机加入组, 同一主机多次出现时合并变量
+func (inventory *ansibleInventory) addHost(groupName string, hostname string, vars map[string]string) {
+	if _, ok := inventory.hostVars[hostname]; !ok {
+		inventory.hostVars[hostname] = make(map[string]string)
+		inventory.hostOrder = append(inventory.hostOrder, hostname)
+	}
+	for key, value := range vars {
+		inventory.hostVars[hostname][key] = value
+	}
+	group := inventory.group(groupName)
+	if !slices.Contains(group.hosts, hostname) {
+		group.hosts = append(group.hosts, hostname)
+	}
+}
+
+// loadAnsibleInventories 读取配置的 Ansible 清单, 目录读取其中的全部文件, 出错的文件被跳过
+func loadAnsibleInventories(paths []string, baseDir string) ([]Host, error) {
+	var hosts []Host
+	var errs []error
+	for _, path := range paths {
+		path = expandHomeDir(path)
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(baseDir, path)
+		}
+		files := []string{path}
+		if info, err := os.Stat(path); err == nil && info.IsDir() {
+			entries, err := os.ReadDir(path)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("读取 Ansible 清单目录失败: %s\n%w", path, err))
+				continue
+			}
+			files = nil
+			for _, entry := range entries {
+				ext := filepath.Ext(entry.Name())
+				// 与 Ansible 一致忽略隐藏文件与常见的非清单文件
+				if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") || ext == ".retry" || ext == ".json" || ext == ".py" || strings.HasSuffix(entry.Name(), "~") {
+					continue
+				}
+				files = append(files, filepath.Join(path, entry.Name()))
+			}
+		}
+		for _, file := range files {
+			fileHosts, err := parseAnsibleInventory(file)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			hosts = append(hosts, fileHosts...)
+		}
+	}
+	return hosts, errors.Join(errs...)
+}
+
+// parseAnsibleInventory 按扩展名解析 YAML 或 INI 格式的清单
+func parseAnsibleInventory(path string) ([]Host, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取 Ansible 清单失败: %s\n%w", path, err)
+	}
+	inventory := newAnsibleInventory()
+	switch filepath.Ext(path) {
+	case ".yaml", ".yml":
+		err = inventory.parseYAML(data)
+	default:
+		err = inventory.parseINI(data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("解析 Ansible 清单失败: %s\n%w", path, err)
+	}
+	return inventory.hosts(), nil
+}
+
+// parseINI 解析 [group]、[group:vars] 与 [group:children] 段, 组外的主机属于 ungrouped
+func (inventory *ansibleInventory) parseINI(data []byte) error {
+	section, kind := "ungrouped", ""
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section, kind, _ = strings.Cut(line[1:len(line)-1], ":")
+			if kind != "" && kind != "vars" && kind != "children" {
+				return fmt.Errorf("第 %d 行: 未知的段类型: %s", lineNumber, kind)
+			}
+			inventory.group(section)
+			continue
+		}
+		fields, err := splitAnsibleFields(line)
+		if err != nil {
+			return fmt.Errorf("第 %d 行: %w", lineNumber, err)
+		}
+		switch kind {
+		case "vars":
+			key, value, ok := strings.Cut(line, "=")
+			if !ok {
+				return fmt.Errorf("第 %d 行: 变量格式无效: %s", lineNumber, line)
+			}
+			inventory.group(section).vars[strings.TrimSpace(key)] = unquoteAnsibleValue(strings.TrimSpace(value))
+		case "children":
+			group := inventory.group(section)
+			if !slices.Contains(group.children, fields[0]) {
+				group.children = append(group.children, fields[0])
+			}
+			inventory.group(fields[0])
+		default:
+			vars := make(map[string]string)
+			for _, field := range fields[1:] {
+				key, value, ok := strings.Cut(field, "=")
+				if !ok {
+					return fmt.Errorf("第 %d 行: 主机变量格式无效: %s", lineNumber, field)
+				}
+				vars[key] = value
+			}
+			name := fields[0]
+			// 主机名后的端口, 如 host:2222, 不处理 IPv6 地址
+			if host, port, ok := strings.Cut(name, ":"); ok && strings.Count(name, ":") == 1 && isValidPort(port) {
+				name = host
+				if _, exists := vars["ansible_port"]; !exists {
+					vars["ansible_port"] = port
+				}
+			}
+			for _, hostname := range expandAnsibleHostRange(name) {
+				inventory.addHost(section, hostname, vars)
+			}
+		}
+	}
+	return scanner.Err()
+}
+
+// splitAnsibleFields 按空白拆分, 引号内的空白保留, 引号本身被去除
+func splitAnsibleFields(line string) ([]string, error) {
+	var fields []string
+	var builder strings.Builder
+	var quote rune
+	hasField := false
+	for _, char := range line {
+		switch {
+		case quote != 0 && char == quote:
+			quote = 0
+		case quote == 0 && (char == '"' || char == '\''):
+			quote = char
+			hasField = true
+		case quote == 0 && (char == ' ' || char == '\t'):
+			if hasField {
+				fields = append(fields, builder.String())
+				builder.Reset()
+				hasField = false
+			}
+		default:
+			builder.WriteRune(char)
+			hasField = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("引号未闭合")
+	}
+	if hasField {
+		fields = append(fields, builder.String())
+	}
+	return fields, nil
+}
+
+func unquoteAnsibleValue(value string) string {
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		return value[1 : len(value)-1]
+	}
+	return value
+}
+
+// expandAnsibleHostRange 展开主机名中的范围, 数字范围保留前导零的位数
+func expandAnsibleHostRange(name string) []string {
+	location := ansibleHostRangePattern.FindStringSubmatchIndex(name)
+	if location == nil {
+		return []string{name}
+	}
+	start, end := name[location[2]:location[3]], name[location[4]:location[5]]
+	step := 1
+	if location[6] != -1 {
+		if value, err := strconv.Atoi(name[location[6]:location[7]]); err == nil && value > 0 {
+			step = value
+		}
+	}
+	prefix, suffix := name[:location[0]], name[location[1]:]
+	var values []string
+	startNumber, startErr := strconv.Atoi(start)
+	endNumber, endErr := strconv.Atoi(end)
+	switch {
+	case startErr == nil && endErr == nil:
+		width := 0
+		if strings.HasPrefix(start, "0") {
+			width = len(start)
+		}
+		for number := startNumber; number <= endNumber; number += step {
+			values = append(values, fmt.Sprintf("%0*d", width, number))
+		}
+	case len(start) == 1 && len(end) == 1:
+		for char := start[0]; char <= end[0]; char += byte(step) {
+			values = append(values, string(char))
+			if int(char)+step > 255 {
+				break
+			}
+		}
+	default:
+		return []string{name}
+	}
+	var names []string
+	for _, value := range values {
+		// 后缀中可能还有范围
+		for _, rest := range expandAnsibleHostRange(suffix) {
+			names = append(names, prefix+value+rest)
+		}
+	}
+	return names
+}
+
+// parseYAML 解析以组名为键的 YAML 清单, 组下为 hosts、vars 与 children
+func (inventory *ansibleInventory) parseYAML(data []byte) error {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return err
+	}
+	if len(root.Content) == 0 {
+		return nil
+	}
+	return inventory.parseYAMLGroups(root.Content[0])
+}
+
+// parseYAMLGroups 按文件中的顺序解析映射中的各个组
+func (inventory *ansibleInventory) parseYAMLGroups(node *yaml.Node) error {
+	if node.Kind != yaml.MappingNode {
+		return fmt.Errorf("第 %d 行: 组应为映射", node.Line)
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if err := inventory.parseYAMLGroup(node.Content[i].Value, node.Content[i+1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (inventory *ansibleInventory) parseYAMLGroup(name string, node *yaml.Node) error {
+	group := inventory.group(name)
+	// 空组写作 group: 或 group: {}
+	if node.Kind == yaml.ScalarNode && node.Tag == "!!null" {
+		return nil
+	}
+	if node.Kind != yaml.MappingNode {
+		return fmt.Errorf("第 %d 行: 组 %s 应为映射", node.Line, name)
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i].Value, node.Content[i+1]
+		switch key {
+		case "hosts":
+			if value.Kind == yaml.ScalarNode && value.Tag == "!!null" {
+				continue
+			}
+			if value.Kind != yaml.MappingNode {
+				return fmt.Errorf("第 %d 行: 组 %s 的 hosts 应为映射", value.Line, name)
+			}
+			for j := 0; j+1 < len(value.Content); j += 2 {
+				vars, err := yamlScalarMap(value.Content[j+1])
+				if err != nil {
+					return err
+				}
+				for _, hostname := range expandAnsibleHostRange(value.Content[j].Value) {
+					inventory.addHost(name, hostname, vars)
+				}
+			}
+		case "vars":
+			vars, err := yamlScalarMap(value)
+			if err != nil {
+				return err
+			}
+			for varKey, varValue := range vars {
+				group.vars[varKey] = varValue
+			}
+		case "children":
+			if value.Kind == yaml.ScalarNode && value.Tag == "!!null" {
+				continue
+			}
+			if value.Kind != yaml.MappingNode {
+				return fmt.Errorf("第 %d 行: 组 %s 的 children 应为映射", value.Line, name)
+			}
+			for j := 0; j+1 < len(value.Content); j += 2 {
+				child := value.Content[j].Value
+				if !slices.Contains(group.children, child) {
+					group.children = append(group.children, child)
+				}
+				if err := inventory.parseYAMLGroup(child, value.Content[j+1]); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// yamlScalarMap 读取变量映射中的标量值, 列表与映射等复杂值被忽略
+func yamlScalarMap(node *yaml.Node) (map[string]string, error) {
+	vars := make(map[string]string)
+	if node.Kind == yaml.ScalarNode && node.Tag == "!!null" {
+		return vars, nil
+	}
+	if node.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("第 %d 行: 变量应为映射", node.Line)
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if value := node.Content[i+1]; value.Kind == yaml.ScalarNode {
+			vars[node.Content[i].Value] = value.Value
+		}
+	}
+	return vars, nil
+}
+
+// hosts 将清单转换为主机列表, 变量优先级为主机变量、所在组、父组、all, 组名作为标签
+func (inventory *ansibleInventory) hosts() []Host {
+	parents := make(map[string][]string)
+	for _, name := range inventory.groupOrder {
+		for _, child := range inventory.groups[name].children {
+			parents[child] = append(parents[child], name)
+		}
+	}
+	hosts := make([]Host, 0, len(inventory.hostOrder))
+	for _, hostname := range inventory.hostOrder {
+		// 由近及远收集所在组及其父组
+		var groups []string
+		for _, name := range inventory.groupOrder {
+			if slices.Contains(inventory.groups[name].hosts, hostname) {
+				groups = append(groups, name)
+			}
+		}
+		for i := 0; i < len(groups); i++ {
+			for _, parent := range parents[groups[i]] {
+				if !slices.Contains(groups, parent) {
+					groups = append(groups, parent)
+				}
+			}
+		}
+		// 由远及近覆盖, all 优先级最低
+		precedence := slices.Clone(groups)
+		slices.Reverse(precedence)
+		vars := make(map[string]string)
+		for _, name := range slices.Concat([]string{"all"}, precedence) {
+			if group, ok := inventory.groups[name]; ok {
+				for key, value := range group.vars {
+					vars[key] = value
+				}
+			}
+		}
+		for key, value := range inventory.hostVars[hostname] {
+			vars[key] = value
+		}
+		var tags []string
+		for _, name := range groups {
+			if name != "all" && name != "ungrouped" {
+				tags = append(tags, name)
+			}
+		}
+		host := Host{
+			Alias:    hostname,
+			Hostname: cmp.Or(vars["ansible_host"], vars["ansible_ssh_host"], hostname),
+			Port:     cmp.Or(vars["ansible_port"], vars["ansible_ssh_port"]),
+			User:     cmp.Or(vars["ansible_user"], vars["ansible_ssh_user"]),
+			Source:   "ansible",
+			Tags:     tags,
+		}
+		for _, key := range []string{"ansible_ssh_common_args", "ansible_ssh_extra_args"} {
+			if match := ansibleProxyJumpPattern.FindStringSubmatch(vars[key]); match != nil {
+				host.ProxyJump = match[1]
+				break
+			}
+		}
+		hosts = append(hosts, host)
+	}
+	return hosts
+}
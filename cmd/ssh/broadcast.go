@@ -0,0 +1,247 @@
+package ssh
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"slices"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+	"trance-cli/internal/logging"
+
+	"github.com/gookit/color"
+)
+
+// 批量执行时按主机轮换的前缀颜色
+var broadcastPrefixColors = []color.Color{color.FgCyan, color.FgGreen, color.FgYellow, color.FgBlue, color.FgMagenta}
+
+type broadcastResult struct {
+	Host     Host
+	ExitCode int
+	Duration time.Duration
+	Err      error
+	TimedOut bool
+}
+
+// isHostPattern 判断主机参数是否为模式, 如 web-*、db?,cache、!prod-* 或 tag:role=web
+func isHostPattern(hostArg string) bool {
+	return strings.ContainsAny(hostArg, "*?,") || strings.HasPrefix(hostArg, "!") || strings.HasPrefix(hostArg, "tag:")
+}
+
+// matchHostsByPattern 以 ssh_config 的模式规则匹配主机别名或主机名, 不包含历史记录
+func matchHostsByPattern(hosts []Host, pattern string) []Host {
+	var matched []Host
+	seen := make(map[string]bool)
+	for _, host := range hosts {
+		if host.History != nil || seen[host.Alias] {
+			continue
+		}
+		if hostMatchesPattern(host, pattern) {
+			seen[host.Alias] = true
+			matched = append(matched, host)
+		}
+	}
+	return matched
+}
+
+// hostMatchesPattern 任一肯定模式命中且否定模式均未命中时匹配
+// tag: 开头的模式匹配主机清单中的标签, 其余模式匹配别名或主机名
+func hostMatchesPattern(host Host, pattern string) bool {
+	included, excluded := false, false
+	for _, item := range strings.Split(strings.ToLower(pattern), ",") {
+		itemPattern, negated := strings.CutPrefix(item, "!")
+		values := []string{host.Alias, host.Hostname}
+		if tagPattern, ok := strings.CutPrefix(itemPattern, "tag:"); ok {
+			itemPattern, values = tagPattern, host.Tags
+		}
+		matched := slices.ContainsFunc(values, func(value string) bool {
+			return matchPattern(strings.ToLower(value), itemPattern)
+		})
+		if negated {
+			excluded = excluded || matched
+		} else {
+			included = included || matched
+		}
+	}
+	return included && !excluded
+}
+
+// hostTarget 返回连接主机所需的参数, ssh_config 来源的主机交由 ssh 按别名解析
+func hostTarget(host Host) (usr, server, port, proxyJump string) {
+	switch {
+	case host.History != nil:
+		usr, server, port = parseHostString(host.History.HostStr)
+		return usr, server, port, host.History.ProxyJump
+	case host.Source == "ssh_config":
+		return "", host.Alias, "", ""
+	}
+	return host.User, host.Hostname, host.Port, host.ProxyJump
+}
+
+// runBroadcast 在多个主机上并发执行远程命令, 输出按行加上主机前缀, 返回是否全部成功
+func (executor *Executor) runBroadcast(hosts []Host, sshOptions []string, proxyJump string, remoteCommandArgs []string) bool {
+	if len(remoteCommandArgs) == 0 {
+		executor.logger.PrintfErr(logging.LogModeAppend, true, "批量执行需要指定远程命令")
+		return false
+	}
+	// 非交互执行, 避免等待密码或确认主机密钥
+	options := append([]string{"-o", "BatchMode=yes"}, sshOptions...)
+	prefixWidth := 0
+	for _, host := range hosts {
+		prefixWidth = max(prefixWidth, len(host.Label()))
+	}
+
+	var outputMutex sync.Mutex
+	results := make([]broadcastResult, len(hosts))
+	semaphore := make(chan struct{}, max(executor.Jobs, 1))
+	var waitGroup sync.WaitGroup
+	for i, host := range hosts {
+		usr, server, port, hostProxyJump := hostTarget(host)
+		if proxyJump != "" {
+			hostProxyJump = proxyJump
+		}
+		args := buildSSHArgs(options, usr, server, port, hostProxyJump, remoteCommandArgs)
+		if executor.DryRun {
+			executor.logger.PrintfOut(logging.LogModeAppend, true, "ssh %s", shellJoin(args))
+			continue
+		}
+		prefixColor := broadcastPrefixColors[i%len(broadcastPrefixColors)]
+		prefix := prefixColor.Sprintf("%-*s", prefixWidth, host.Label()) + " | "
+		waitGroup.Add(1)
+		go func() {
+			defer waitGroup.Done()
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+			stdout := &prefixWriter{writer: executor.logger.OutWriter, mutex: &outputMutex, prefix: prefix}
+			stderr := &prefixWriter{writer: executor.logger.ErrWriter, mutex: &outputMutex, prefix: prefix}
+			results[i] = runBroadcastSSH(host, args, executor.Timeout, stdout, stderr)
+			stdout.Flush()
+			stderr.Flush()
+		}()
+	}
+	if executor.DryRun {
+		return true
+	}
+	waitGroup.Wait()
+	return executor.printBroadcastResults(results)
+}
+
+func runBroadcastSSH(host Host, args []string, timeout time.Duration, stdout io.Writer, stderr io.Writer) broadcastResult {
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	result := broadcastResult{Host: host}
+	startTime := time.Now()
+	cmd := exec.CommandContext(ctx, "ssh", args...)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	// 超时杀死 ssh 后不再等待残留的输出
+	cmd.WaitDelay = time.Second
+	err := cmd.Run()
+	result.Duration = time.Since(startTime)
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		result.ExitCode = -1
+		result.TimedOut = true
+	case errors.As(err, &exitErr):
+		result.ExitCode = exitErr.ExitCode()
+	default:
+		result.ExitCode = -1
+		result.Err = err
+	}
+	return result
+}
+
+// printBroadcastResults 输出各主机的退出码汇总表, 返回是否全部成功
+func (executor *Executor) printBroadcastResults(results []broadcastResult) bool {
+	successColor := color.New(color.FgGreen, color.Bold)
+	errorColor := color.New(color.FgRed, color.Bold)
+	succeeded := 0
+	executor.logger.PrintfOut(logging.LogModeAppend, true, "")
+	writer := tabwriter.NewWriter(executor.logger.OutWriter, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(writer, "HOST\tEXIT\tTIME\tSTATUS")
+	for _, result := range results {
+		var status string
+		switch {
+		case result.TimedOut:
+			status = "timeout"
+		case result.Err != nil:
+			status = "error: " + strings.SplitN(result.Err.Error(), "\n", 2)[0]
+		case result.ExitCode == 255:
+			// ssh 自身出错时返回 255
+			status = "ssh error"
+		case result.ExitCode != 0:
+			status = "failed"
+		default:
+			status = "ok"
+			succeeded++
+		}
+		exitCode := "-"
+		if result.ExitCode >= 0 {
+			exitCode = fmt.Sprintf("%d", result.ExitCode)
+		}
+		_, _ = fmt.Fprintf(writer, "%s\t%s\t%s\t%s\n", result.Host.Label(), exitCode, result.Duration.Round(10*time.Millisecond), status)
+	}
+	_ = writer.Flush()
+	summary := fmt.Sprintf("%d/%d 个主机执行成功", succeeded, len(results))
+	if succeeded == len(results) {
+		executor.logger.PrintfOut(logging.LogModeAppend, true, "%s", successColor.Sprint(summary))
+		return true
+	}
+	executor.logger.PrintfOut(logging.LogModeAppend, true, "%s", errorColor.Sprint(summary))
+	return false
+}
+
+// prefixWriter 按行写入, 每行加上主机前缀, 多个主机共享互斥锁避免行交错
+type prefixWriter struct {
+	writer  io.Writer
+	mutex   *sync.Mutex
+	prefix  string
+	pending []byte
+}
+
+func (writer *prefixWriter) Write(data []byte) (int, error) {
+	writer.pending = append(writer.pending, data...)
+	index := bytes.LastIndexByte(writer.pending, '\n')
+	if index == -1 {
+		return len(data), nil
+	}
+	lines := writer.pending[:index+1]
+	var builder strings.Builder
+	for _, line := range bytes.SplitAfter(lines, []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+		builder.WriteString(writer.prefix)
+		builder.Write(line)
+	}
+	writer.mutex.Lock()
+	_, err := io.WriteString(writer.writer, builder.String())
+	writer.mutex.Unlock()
+	writer.pending = append(writer.pending[:0], writer.pending[index+1:]...)
+	if err != nil {
+		return 0, err
+	}
+	return len(data), nil
+}
+
+// Flush 输出末尾没有换行的剩余内容
+func (writer *prefixWriter) Flush() {
+	if len(writer.pending) == 0 {
+		return
+	}
+	writer.mutex.Lock()
+	_, _ = io.WriteString(writer.writer, writer.prefix+string(writer.pending)+"\n")
+	writer.mutex.Unlock()
+	writer.pending = nil
+}
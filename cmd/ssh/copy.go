@@ -0,0 +1,211 @@
+package ssh
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"trance-cli/internal/logging"
+	"trance-cli/internal/system"
+
+	"github.com/spf13/cobra"
+)
+
+var copyExecutor = &CopyExecutor{}
+
+var copyCmd = &cobra.Command{
+	Use:   "cp [flags] <source> [source ...] <target> [-- scp-or-rsync-options]",
+	Short: "Copy files with scp or rsync, picking the remote host with the selector",
+	Long: "Remote paths are written as host:path like scp. A remote path without a host such as :/tmp opens the host selector, " +
+		"its user, port, ProxyJump and SSH options are used for the transfer in the same way as for ssh.",
+	Args: cobra.MinimumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		copyExecutor.Run(cmd, args)
+	},
+}
+
+func registerCopy(parentCmd *cobra.Command) {
+	copyCmd.Flags().BoolVarP(&copyExecutor.DryRun, "dry-run", "n", false, "Print the command that would be executed, but do not execute it")
+	copyCmd.Flags().BoolVarP(&copyExecutor.Recursive, "recursive", "r", false, "Copy directories recursively")
+	copyCmd.Flags().BoolVar(&copyExecutor.Rsync, "rsync", false, "Transfer with rsync -e ssh instead of scp")
+	copyCmd.Flags().StringVarP(&copyExecutor.ProxyJump, "proxy-jump", "J", "", "Jump host as user@host:port or alias, for remote paths with an explicit host")
+	copyCmd.Flags().BoolVar(&copyExecutor.NoProbe, "no-probe", false, "Do not probe TCP reachability of the hosts visible in the selector")
+	parentCmd.AddCommand(copyCmd)
+}
+
+type CopyExecutor struct {
+	logger    logging.Logger
+	DryRun    bool
+	Recursive bool
+	Rsync     bool
+	ProxyJump string
+	NoProbe   bool
+}
+
+// transferPath 为命令行中的一个源或目标路径
+type transferPath struct {
+	Remote bool
+	// 远程主机, 为空时通过选择器选择
+	Host string
+	Path string
+}
+
+// parseTransferPath 按 scp 规则识别远程路径, 首个 / 之前出现冒号时为 host:path, 主机可以是 [::1] 形式的 IPv6 地址
+func parseTransferPath(arg string) transferPath {
+	inBracket := false
+	for i, char := range arg {
+		switch {
+		case char == '[':
+			inBracket = true
+		case char == ']':
+			inBracket = false
+		case char == '/' && !inBracket:
+			return transferPath{Path: arg}
+		case char == ':' && !inBracket:
+			return transferPath{Remote: true, Host: arg[:i], Path: arg[i+1:]}
+		}
+	}
+	return transferPath{Path: arg}
+}
+
+func (executor *CopyExecutor) Run(cmd *cobra.Command, args []string) {
+	executor.logger = logging.Logger{
+		OutWriter: cmd.OutOrStdout(),
+		ErrWriter: cmd.ErrOrStderr(),
+		State:     logging.LoggerStateNewLine,
+	}
+	// -- 之后的参数原样传给 scp 或 rsync
+	var extraArgs []string
+	if dash := cmd.ArgsLenAtDash(); dash != -1 {
+		args, extraArgs = args[:dash], args[dash:]
+	}
+	if len(args) < 2 {
+		executor.logger.PrintfErr(logging.LogModeAppend, true, "至少需要一个源路径与一个目标路径")
+		os.Exit(1)
+	}
+	program := "scp"
+	if executor.Rsync {
+		program = "rsync"
+	}
+	if !system.IsCommandAvailable(program) {
+		executor.logger.PrintfErr(logging.LogModeAppend, true, "未找到 %s 命令", program)
+		os.Exit(1)
+	}
+
+	paths := make([]transferPath, len(args))
+	remoteHost := ""
+	selectHost := false
+	remoteCount := 0
+	for i, arg := range args {
+		paths[i] = parseTransferPath(arg)
+		if !paths[i].Remote {
+			continue
+		}
+		remoteCount++
+		if paths[i].Host == "" {
+			selectHost = true
+			continue
+		}
+		// 连接参数对整个命令生效, 无法为多个主机分别设置
+		if remoteHost != "" && remoteHost != paths[i].Host {
+			executor.logger.PrintfErr(logging.LogModeAppend, true, "只支持一个远程主机: %s, %s", remoteHost, paths[i].Host)
+			os.Exit(1)
+		}
+		remoteHost = paths[i].Host
+	}
+	if remoteCount == 0 {
+		executor.logger.PrintfErr(logging.LogModeAppend, true, "至少需要一个远程路径, 如 host:path 或 :path")
+		os.Exit(1)
+	}
+	if selectHost && remoteHost != "" {
+		executor.logger.PrintfErr(logging.LogModeAppend, true, "选择器选择的主机不能与 %s 同时使用", remoteHost)
+		os.Exit(1)
+	}
+	if executor.Rsync && paths[len(paths)-1].Remote && remoteCount > 1 {
+		executor.logger.PrintfErr(logging.LogModeAppend, true, "rsync 不支持源与目标均为远程路径")
+		os.Exit(1)
+	}
+
+	hostStr, proxyJump := remoteHost, executor.ProxyJump
+	var sshOptions []string
+	if selectHost {
+		tuiResult, ok := executor.selectRemoteHost()
+		if !ok {
+			os.Exit(1)
+		}
+		hostStr, proxyJump, sshOptions = tuiResult.HostStr, tuiResult.ProxyJump, tuiResult.SshOptions
+	}
+	usr, server, port := parseHostString(hostStr)
+	connectionOptions := sshConnectionOptions(sshOptions, usr, port, proxyJump)
+
+	var transferArgs []string
+	if executor.Rsync {
+		transferArgs = append(transferArgs, "-e", shellJoin(append([]string{"ssh"}, connectionOptions...)))
+	} else {
+		// scp 的选项与 ssh 不同, 将 ssh 参数统一转换为 -o Key=Value
+		lines, err := sshOptionConfigLines(connectionOptions)
+		if err != nil {
+			executor.logger.PrintfErr(logging.LogModeAppend, true, "scp 无法使用的 ssh 参数\n%s", err.Error())
+			os.Exit(1)
+		}
+		for _, line := range lines {
+			transferArgs = append(transferArgs, "-o", line[0]+"="+line[1])
+		}
+	}
+	if executor.Recursive {
+		transferArgs = append(transferArgs, "-r")
+	}
+	transferArgs = append(transferArgs, extraArgs...)
+	for _, path := range paths {
+		if path.Remote {
+			transferArgs = append(transferArgs, bracketIPv6(server)+":"+path.Path)
+		} else {
+			transferArgs = append(transferArgs, path.Path)
+		}
+	}
+
+	executor.logger.PrintfOut(logging.LogModeAppend, true, "%s %s", program, shellJoin(transferArgs))
+	if executor.DryRun {
+		return
+	}
+	transferCmd := exec.Command(program, transferArgs...)
+	transferCmd.Stdin = os.Stdin
+	transferCmd.Stdout = os.Stdout
+	transferCmd.Stderr = os.Stderr
+	if err := transferCmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.ExitCode())
+		}
+		executor.logger.PrintfErr(logging.LogModeAppend, true, "%s", err.Error())
+		os.Exit(1)
+	}
+}
+
+// selectRemoteHost 使用与 ssh 相同的主机列表与选择器选择远程主机, 远程命令与端口转发不适用于文件传输
+func (executor *CopyExecutor) selectRemoteHost() (TuiResult, bool) {
+	hostExecutor := &Executor{logger: executor.logger}
+	hosts, err := hostExecutor.collectSSHHosts()
+	if err != nil {
+		executor.logger.PrintfErr(logging.LogModeAppend, true, "%s", err.Error())
+		return TuiResult{}, false
+	}
+	tuiResult, err := RunSelector(hosts, SelectorOptions{
+		KnownHosts: hostExecutor.knownHosts,
+		Probe:      !executor.NoProbe,
+	})
+	if err != nil {
+		// 用户可能按 ESC 退出, 此时不应视为错误
+		if err.Error() != "未选择主机" {
+			executor.logger.PrintfErr(logging.LogModeAppend, true, "%s", err.Error())
+		}
+		return TuiResult{}, false
+	}
+	if len(tuiResult.Hosts) > 0 {
+		executor.logger.PrintfErr(logging.LogModeAppend, true, "文件传输不支持多个主机")
+		return TuiResult{}, false
+	}
+	if len(tuiResult.RemoteCommandArgs) > 0 || len(tuiResult.Forwards) > 0 {
+		executor.logger.PrintfErr(logging.LogModeAppend, true, "文件传输忽略远程命令与端口转发")
+	}
+	return tuiResult, true
+}
@@ -2,16 +2,20 @@ package ssh
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"os/exec"
 	"os/user"
 	"path/filepath"
+	"slices"
 	"sort"
+	"strconv"
 	"strings"
+	"time"
 	"trance-cli/internal/logging"
 
-	"github.com/kevinburke/ssh_config"
 	"github.com/spf13/cobra"
 )
 
@@ -22,16 +26,44 @@ type Host struct {
 	User      string
 	ProxyJump string
 	Source    string
+	// 主机清单中的标签与备注, 标签为 label 或 key=value
+	Tags []string
+	Note string
+	// 是否在 known_hosts 中存在公钥, 以及首个公钥的类型与指纹
+	Known       bool
+	KeyType     string
+	Fingerprint string
+	// history 来源的主机对应的历史记录
+	History *HistoryEntry
 }
 
 func (host Host) FilterValue() string {
+	return host.Label()
+}
+
+// Label 为表格中展示的名称, 历史记录显示完整命令行
+func (host Host) Label() string {
+	if host.History != nil {
+		return host.History.Command()
+	}
 	return host.Alias
 }
 
 type Executor struct {
-	logger logging.Logger
-	DryRun bool
-	Proxy  bool
+	logger     logging.Logger
+	knownHosts *KnownHosts
+	DryRun     bool
+	Proxy      bool
+	Resolve    bool
+	// 批量执行的并发数与单个主机的超时时间, 超时为 0 时不限制
+	Jobs    int
+	Timeout time.Duration
+	// 端口转发, 保持转发模式下断开后自动重连
+	Forwards  []string
+	KeepAlive bool
+	// 选择器中不探测主机可达性, 以及探测时读取 SSH banner
+	NoProbe     bool
+	ProbeBanner bool
 }
 
 func (executor *Executor) Run(cmd *cobra.Command, args []string) {
@@ -65,11 +97,43 @@ func (executor *Executor) Run(cmd *cobra.Command, args []string) {
 		remoteCommandArgs = args[separatorIndexes[1]+1:]
 	}
 
-	for _, arg := range wrapperArgs {
+	for i := 0; i < len(wrapperArgs); i++ {
+		arg := wrapperArgs[i]
 		if arg == "-n" || arg == "--dry-run" {
 			executor.DryRun = true
 		} else if arg == "-p" || arg == "--proxy" {
 			executor.Proxy = true
+		} else if arg == "-g" || arg == "--resolve" {
+			executor.Resolve = true
+		} else if arg == "-k" || arg == "--keep-alive" {
+			executor.KeepAlive = true
+		} else if arg == "--no-probe" {
+			executor.NoProbe = true
+		} else if arg == "--banner" {
+			executor.ProbeBanner = true
+		} else if arg == "-f" || arg == "--forward" || strings.HasPrefix(arg, "--forward=") {
+			value, ok := wrapperFlagValue(wrapperArgs, &i)
+			if _, err := parseForward(value); !ok || err != nil {
+				executor.logger.PrintfErr(logging.LogModeAppend, true, "端口转发无效: %s", value)
+				os.Exit(1)
+			}
+			executor.Forwards = append(executor.Forwards, value)
+		} else if arg == "-j" || arg == "--jobs" || strings.HasPrefix(arg, "--jobs=") {
+			value, ok := wrapperFlagValue(wrapperArgs, &i)
+			jobs, err := strconv.Atoi(value)
+			if !ok || err != nil || jobs < 1 {
+				executor.logger.PrintfErr(logging.LogModeAppend, true, "并发数无效: %s", value)
+				os.Exit(1)
+			}
+			executor.Jobs = jobs
+		} else if arg == "-t" || arg == "--timeout" || strings.HasPrefix(arg, "--timeout=") {
+			value, ok := wrapperFlagValue(wrapperArgs, &i)
+			timeout, err := time.ParseDuration(value)
+			if !ok || err != nil || timeout < 0 {
+				executor.logger.PrintfErr(logging.LogModeAppend, true, "超时时间无效: %s", value)
+				os.Exit(1)
+			}
+			executor.Timeout = timeout
 		} else if arg == "-h" || arg == "--help" {
 			err := cmd.Help()
 			if err != nil {
@@ -95,14 +159,57 @@ func (executor *Executor) Run(cmd *cobra.Command, args []string) {
 		executor.logger.PrintfErr(logging.LogModeAppend, true, "未找到可供选择的主机, 请检查配置")
 		os.Exit(1)
 	}
-	// 代理模式下忽略用户传入的 sshOptions remoteCommandArgs
+	// 代理模式即在 0.0.0.0:1080 上的动态转发
 	if executor.Proxy {
-		sshOptions = []string{}
-		sshOptions = append(sshOptions, "-CqTNn", "-D", "0.0.0.0:1080")
-		remoteCommandArgs = []string{}
+		executor.Forwards = append(executor.Forwards, "D:0.0.0.0:1080")
+	}
+	// 主机参数为模式时不进入 TUI, 直接在匹配的主机上批量执行
+	if hostArg != "" && isHostPattern(hostArg) {
+		if len(executor.Forwards) > 0 || executor.KeepAlive {
+			executor.logger.PrintfErr(logging.LogModeAppend, true, "多个主机不支持端口转发")
+			os.Exit(1)
+		}
+		matchedHosts := matchHostsByPattern(hosts, hostArg)
+		if len(matchedHosts) == 0 {
+			executor.logger.PrintfErr(logging.LogModeAppend, true, "没有与模式匹配的主机: %s", hostArg)
+			os.Exit(1)
+		}
+		if !executor.runBroadcast(matchedHosts, sshOptions, "", remoteCommandArgs) {
+			os.Exit(1)
+		}
+		return
+	}
+	presets, err := loadForwardPresets()
+	if err != nil {
+		executor.logger.PrintfErr(logging.LogModeAppend, true, "%s", err.Error())
+	}
+	snippets, err := loadSnippets()
+	if err != nil {
+		executor.logger.PrintfErr(logging.LogModeAppend, true, "%s", err.Error())
+	}
+	// 命令行未指定端口转发时, 使用主机参数对应的预设
+	initialForwards := executor.Forwards
+	if len(initialForwards) == 0 && hostArg != "" {
+		_, server, _ := parseHostString(hostArg)
+		host := Host{Alias: server, Hostname: server}
+		if index := slices.IndexFunc(hosts, func(host Host) bool { return host.History == nil && host.Alias == server }); index != -1 {
+			host = hosts[index]
+		}
+		initialForwards = presetForwards(presets, host)
 	}
 	// 初始参数传递给 TUI
-	tuiResult, err := RunSelector(hosts, hostArg, sshOptions, remoteCommandArgs)
+	tuiResult, err := RunSelector(hosts, SelectorOptions{
+		HostStr:           hostArg,
+		SshOptions:        sshOptions,
+		RemoteCommandArgs: remoteCommandArgs,
+		Resolve:           executor.Resolve,
+		KnownHosts:        executor.knownHosts,
+		Forwards:          initialForwards,
+		ForwardPresets:    presets,
+		Probe:             !executor.NoProbe,
+		ProbeBanner:       executor.ProbeBanner,
+		Snippets:          snippets,
+	})
 	if err != nil {
 		// 用户可能按 ESC 退出, 此时不应视为错误
 		if err.Error() != "未选择主机" {
@@ -111,12 +218,62 @@ func (executor *Executor) Run(cmd *cobra.Command, args []string) {
 		os.Exit(1)
 	}
 
+	// TUI 中标记了多个主机时批量执行
+	if len(tuiResult.Hosts) > 0 {
+		if len(tuiResult.Forwards) > 0 || executor.KeepAlive {
+			executor.logger.PrintfErr(logging.LogModeAppend, true, "多个主机不支持端口转发")
+			os.Exit(1)
+		}
+		if !executor.runBroadcast(tuiResult.Hosts, tuiResult.SshOptions, tuiResult.ProxyJump, tuiResult.RemoteCommandArgs) {
+			os.Exit(1)
+		}
+		return
+	}
+
 	finalUsr, finalServer, finalPort := parseHostString(tuiResult.HostStr)
 	finalProxyJump := tuiResult.ProxyJump
 	finalSshOptions := tuiResult.SshOptions
 	finalRemoteCommandArgs := tuiResult.RemoteCommandArgs
+	finalForwards := tuiResult.Forwards
+	if executor.KeepAlive {
+		if len(finalForwards) == 0 {
+			executor.logger.PrintfErr(logging.LogModeAppend, true, "保持转发模式需要至少一个端口转发")
+			os.Exit(1)
+		}
+		if len(finalRemoteCommandArgs) > 0 {
+			executor.logger.PrintfErr(logging.LogModeAppend, true, "保持转发模式不支持远程命令")
+			os.Exit(1)
+		}
+	}
 
-	err = executor.runSSH(finalSshOptions, finalUsr, finalServer, finalPort, finalProxyJump, finalRemoteCommandArgs)
+	if !executor.DryRun {
+		err := appendHistory(HistoryEntry{
+			HostStr:           tuiResult.HostStr,
+			ProxyJump:         finalProxyJump,
+			SshOptions:        finalSshOptions,
+			RemoteCommandArgs: finalRemoteCommandArgs,
+			Forwards:          forwardSpecs(finalForwards),
+			Time:              time.Now(),
+		})
+		if err != nil {
+			executor.logger.PrintfErr(logging.LogModeAppend, true, "%s", err.Error())
+		}
+	}
+	// 端口转发参数位于用户的 sshOptions 之前
+	finalSshOptions = append(forwardArgs(finalForwards), finalSshOptions...)
+	if executor.KeepAlive {
+		// runTunnel 已添加 -N, 代理模式同样启用压缩与静默模式, 不分配终端也不读取标准输入
+		if executor.Proxy {
+			finalSshOptions = append([]string{"-CqTn"}, finalSshOptions...)
+		}
+		err = executor.runTunnel(buildSSHArgs(finalSshOptions, finalUsr, finalServer, finalPort, finalProxyJump, nil), finalForwards)
+	} else {
+		// 代理模式没有远程命令时只保持转发
+		if executor.Proxy && len(finalRemoteCommandArgs) == 0 {
+			finalSshOptions = append([]string{"-CqTNn"}, finalSshOptions...)
+		}
+		err = executor.runSSH(finalSshOptions, finalUsr, finalServer, finalPort, finalProxyJump, finalRemoteCommandArgs)
+	}
 	if err != nil {
 		executor.logger.PrintfErr(logging.LogModeAppend, true, "%s", err.Error())
 	}
@@ -131,29 +288,48 @@ func (executor *Executor) collectSSHHosts() ([]Host, error) {
 		return nil, fmt.Errorf("获取当前用户失败\n%w", err)
 	}
 	homeDir := usr.HomeDir
-	// 解析 ssh_config
-	configPaths := []string{
-		filepath.Join(homeDir, ".ssh", "config"),
-		"/etc/ssh/ssh_config",
+	// 解析 ssh_config, 与 OpenSSH 一样先读用户配置再读系统配置, 每项取首个生效的值
+	sshConfig := NewSSHConfig()
+	sshDir := filepath.Join(homeDir, ".ssh")
+	if err := sshConfig.ReadFile(filepath.Join(sshDir, "config"), sshDir); err != nil {
+		executor.logger.PrintfErr(logging.LogModeAppend, true, "%s", err.Error())
+	}
+	if err := sshConfig.ReadFile("/etc/ssh/ssh_config", "/etc/ssh"); err != nil {
+		executor.logger.PrintfErr(logging.LogModeAppend, true, "%s", err.Error())
 	}
-	configDPath := filepath.Join(homeDir, ".ssh", "config.d")
+	addSSHConfigHosts(sshConfig, usr.Username, hostMap)
+	// 未被 Include 的 config.d 文件各自独立解析, 与 ssh -F 一样只使用文件自身的配置
+	// 避免 ~/.ssh/config 中 Host * 的 User/Port 先于文件内主机自身的值生效, 别名重复时 ~/.ssh/config 优先
+	configDPath := filepath.Join(sshDir, "config.d")
 	if entries, err := os.ReadDir(configDPath); err == nil {
 		for _, entry := range entries {
-			configPaths = append(configPaths, filepath.Join(configDPath, entry.Name()))
+			path := filepath.Join(configDPath, entry.Name())
+			if entry.IsDir() || sshConfig.Visited(path) {
+				continue
+			}
+			fileConfig := NewSSHConfig()
+			if err := fileConfig.ReadFile(path, sshDir); err != nil {
+				executor.logger.PrintfErr(logging.LogModeAppend, true, "%s", err.Error())
+			}
+			addSSHConfigHosts(fileConfig, usr.Username, hostMap)
 		}
 	}
-	for _, path := range configPaths {
-		err := parseSSHConfig(path, hostMap)
-		if err != nil {
-			executor.logger.PrintfErr(logging.LogModeAppend, true, "%s", err.Error())
-		}
+	// 主机清单与 Ansible 清单, 与 ssh_config 主机名相同的条目只补充标签与备注
+	inventory, err := loadInventory()
+	if err != nil {
+		executor.logger.PrintfErr(logging.LogModeAppend, true, "%s", err.Error())
 	}
-	// 解析 known_hosts
-	knownHostsPath := filepath.Join(homeDir, ".ssh", "known_hosts")
-	err = parseKnownHosts(knownHostsPath, hostMap)
+	addInventoryHosts(inventory, hostMap)
+	// 解析 known_hosts, 哈希主机名无法还原, 只能在之后以已知主机名匹配
+	executor.knownHosts = &KnownHosts{}
+	err = executor.knownHosts.Load(filepath.Join(homeDir, ".ssh", "known_hosts"))
 	if err != nil {
 		executor.logger.PrintfErr(logging.LogModeAppend, true, "%s", err.Error())
 	}
+	if err := executor.knownHosts.Load("/etc/ssh/ssh_known_hosts"); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		executor.logger.PrintfErr(logging.LogModeAppend, true, "%s", err.Error())
+	}
+	executor.knownHosts.addPlainHosts(hostMap)
 	// 解析 etc_hosts
 	err = parseEtcHosts(hostMap)
 	if err != nil {
@@ -168,8 +344,10 @@ func (executor *Executor) collectSSHHosts() ([]Host, error) {
 	// 按照 Source 分组排序, 组内按 Alias 排序
 	sourceOrder := map[string]int{
 		"ssh_config":  0,
-		"etc_hosts":   1,
-		"known_hosts": 2,
+		"inventory":   1,
+		"ansible":     2,
+		"etc_hosts":   3,
+		"known_hosts": 4,
 	}
 	sort.Slice(hosts, func(i, j int) bool {
 		orderI, okI := sourceOrder[hosts[i].Source]
@@ -187,97 +365,38 @@ func (executor *Executor) collectSSHHosts() ([]Host, error) {
 		return hosts[i].Alias < hosts[j].Alias
 	})
 
-	return hosts, nil
-}
-
-func parseSSHConfig(path string, hostMap map[string]Host) error {
-	f, err := os.Open(path)
+	// 历史记录按 frecency 排在最前
+	history, err := loadHistory()
 	if err != nil {
-		return fmt.Errorf("读取 SSH 配置文件失败: %s\n%w", path, err)
-	}
-	defer func(file *os.File) {
-		_ = file.Close()
-	}(f)
-
-	cfg, err := ssh_config.Decode(f)
-	if err != nil {
-		return fmt.Errorf("解析 SSH 配置文件失败: %s\n%w", path, err)
+		executor.logger.PrintfErr(logging.LogModeAppend, true, "%s", err.Error())
 	}
-	for _, host := range cfg.Hosts {
-		for _, p := range host.Patterns {
-			alias := p.String()
-			// 忽略通配符
-			if strings.ContainsAny(alias, "*?") {
-				continue
-			}
-			// 已存在更高优先级的配置, 跳过
-			if _, ok := hostMap[alias]; ok {
-				continue
-			}
-
-			hostname, _ := cfg.Get(alias, "HostName")
-			if hostname == "" {
-				hostname = alias
-			}
-
-			port, _ := cfg.Get(alias, "Port")
-			usr, _ := cfg.Get(alias, "User")
-			proxy, _ := cfg.Get(alias, "ProxyJump")
-
-			hostMap[alias] = Host{
-				Alias:     alias,
-				Hostname:  hostname,
-				Port:      port,
-				User:      usr,
-				ProxyJump: proxy,
-				Source:    "ssh_config",
-			}
-		}
+	hosts = append(historyHosts(history, hosts, time.Now()), hosts...)
+	// 以 ssh_config、etc_hosts 与历史记录中的主机名匹配 known_hosts
+	for i := range hosts {
+		executor.knownHosts.Annotate(&hosts[i])
 	}
-	return nil
+	return hosts, nil
 }
 
-func parseKnownHosts(path string, hostMap map[string]Host) error {
-	file, err := os.Open(path)
-	if err != nil {
-		return fmt.Errorf("读取 known_hosts 文件失败: %s\n%w", path, err)
-	}
-	defer func(file *os.File) {
-		_ = file.Close()
-	}(file)
-
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		fields := strings.SplitN(scanner.Text(), " ", 2)
-		if len(fields) > 0 {
-			hostsStr := strings.Split(fields[0], ",")
-			for _, hostStr := range hostsStr {
-				// 跳过 Hashed Hostnames
-				if strings.HasPrefix(hostStr, "|") {
-					continue
-				}
-				hostname := hostStr
-				port := ""
-				// known_hosts 中 IPv6 地址格式为 [hostname]:port
-				if strings.HasPrefix(hostname, "[") && strings.Contains(hostname, "]:") {
-					parts := strings.SplitN(strings.TrimPrefix(hostname, "["), "]:", 2)
-					if len(parts) == 2 {
-						hostname = parts[0]
-						port = parts[1]
-					}
-				}
-				if _, ok := hostMap[hostname]; !ok {
-					hostMap[hostname] = Host{
-						Alias:    hostname,
-						Hostname: hostname,
-						Port:     port,
-						Source:   "known_hosts",
-					}
-				}
-			}
+func addSSHConfigHosts(sshConfig *SSHConfig, localUser string, hostMap map[string]Host) {
+	for _, alias := range sshConfig.Aliases() {
+		if _, ok := hostMap[alias]; ok {
+			continue
+		}
+		options := sshConfig.Resolve(alias, localUser)
+		hostname := options["hostname"]
+		if hostname == "" {
+			hostname = alias
+		}
+		hostMap[alias] = Host{
+			Alias:     alias,
+			Hostname:  hostname,
+			Port:      options["port"],
+			User:      options["user"],
+			ProxyJump: options["proxyjump"],
+			Source:    "ssh_config",
 		}
 	}
-	return nil
 }
 
 func parseEtcHosts(hostMap map[string]Host) error {
@@ -331,7 +450,27 @@ func parseHostString(hostStr string) (usr, server, port string) {
 	return
 }
 
-func (executor *Executor) runSSH(sshOptions []string, user string, server string, port string, proxyJump string, remoteCommandArgs []string) error {
+// wrapperFlagValue 读取 --flag=value 或 --flag value 形式的参数值, index 指向已消费的最后一个参数
+func wrapperFlagValue(args []string, index *int) (string, bool) {
+	if _, value, ok := strings.Cut(args[*index], "="); ok {
+		return value, true
+	}
+	if *index+1 >= len(args) {
+		return "", false
+	}
+	*index++
+	return args[*index], true
+}
+
+func buildSSHArgs(sshOptions []string, user string, server string, port string, proxyJump string, remoteCommandArgs []string) []string {
+	finalArgs := sshConnectionOptions(sshOptions, user, port, proxyJump)
+	finalArgs = append(finalArgs, server)
+	finalArgs = append(finalArgs, remoteCommandArgs...)
+	return finalArgs
+}
+
+// sshConnectionOptions 将 User/Port/ProxyJump 转换为 -o 参数追加在 sshOptions 之后, ssh 取首个值, 因此 sshOptions 优先
+func sshConnectionOptions(sshOptions []string, user string, port string, proxyJump string) []string {
 	finalArgs := []string{}
 	finalArgs = append(finalArgs, sshOptions...)
 	if user != "" {
@@ -343,9 +482,12 @@ func (executor *Executor) runSSH(sshOptions []string, user string, server string
 	if proxyJump != "" {
 		finalArgs = append(finalArgs, "-o", fmt.Sprintf("ProxyJump=%s", proxyJump))
 	}
-	finalArgs = append(finalArgs, server)
-	finalArgs = append(finalArgs, remoteCommandArgs...)
-	executor.logger.PrintfOut(logging.LogModeAppend, true, "ssh %s", strings.Join(finalArgs, " "))
+	return finalArgs
+}
+
+func (executor *Executor) runSSH(sshOptions []string, user string, server string, port string, proxyJump string, remoteCommandArgs []string) error {
+	finalArgs := buildSSHArgs(sshOptions, user, server, port, proxyJump, remoteCommandArgs)
+	executor.logger.PrintfOut(logging.LogModeAppend, true, "ssh %s", shellJoin(finalArgs))
 	if executor.DryRun {
 		return nil
 	}
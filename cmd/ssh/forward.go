@@ -0,0 +1,315 @@
+package ssh
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+	"trance-cli/internal/logging"
+
+	"github.com/gookit/color"
+	"github.com/pelletier/go-toml/v2"
+)
+
+const (
+	// 等待转发端口开始监听的最长时间
+	forwardListenTimeout = 30 * time.Second
+	// 连接持续超过该时间后, 重连间隔重新从最小值开始
+	tunnelStableDuration = 30 * time.Second
+	tunnelMinBackoff     = time.Second
+	tunnelMaxBackoff     = time.Minute
+)
+
+// tunnelSSHOptions 只保持转发, 转发失败时退出以便重连, 并通过心跳检测断开的连接
+var tunnelSSHOptions = []string{"-N", "-o", "ExitOnForwardFailure=yes", "-o", "ServerAliveInterval=15", "-o", "ServerAliveCountMax=3"}
+
+// Forward 为一个端口转发, Kind 为 L、R 或 D, 动态转发没有目标
+type Forward struct {
+	Kind        string
+	BindAddress string
+	BindPort    string
+	TargetHost  string
+	TargetPort  string
+}
+
+// parseForward 解析 <L|R|D>:[bind_address:]port[:host:hostport] 形式的转发, 如 L:5432:db:5432 或 D:1080
+func parseForward(spec string) (Forward, error) {
+	kind, rest, ok := strings.Cut(spec, ":")
+	kind = strings.ToUpper(kind)
+	if !ok || (kind != "L" && kind != "R" && kind != "D") {
+		return Forward{}, fmt.Errorf("端口转发格式无效, 应以 L:、R: 或 D: 开头: %s", spec)
+	}
+	parts := splitForwardSpec(rest)
+	forward := Forward{Kind: kind}
+	switch {
+	case kind == "D" && len(parts) == 1:
+		forward.BindPort = parts[0]
+	case kind == "D" && len(parts) == 2:
+		forward.BindAddress, forward.BindPort = parts[0], parts[1]
+	case kind != "D" && len(parts) == 3:
+		forward.BindPort, forward.TargetHost, forward.TargetPort = parts[0], parts[1], parts[2]
+	case kind != "D" && len(parts) == 4:
+		forward.BindAddress, forward.BindPort, forward.TargetHost, forward.TargetPort = parts[0], parts[1], parts[2], parts[3]
+	default:
+		return Forward{}, fmt.Errorf("端口转发格式无效: %s", spec)
+	}
+	if !isValidPort(forward.BindPort) || (kind != "D" && (forward.TargetHost == "" || !isValidPort(forward.TargetPort))) {
+		return Forward{}, fmt.Errorf("端口转发的端口无效: %s", spec)
+	}
+	return forward, nil
+}
+
+// parseForwards 依次解析多个转发, 遇到无效的转发时返回错误
+func parseForwards(specs []string) ([]Forward, error) {
+	var forwards []Forward
+	for _, spec := range specs {
+		forward, err := parseForward(spec)
+		if err != nil {
+			return nil, err
+		}
+		forwards = append(forwards, forward)
+	}
+	return forwards, nil
+}
+
+// splitForwardSpec 按冒号拆分, 方括号内的 IPv6 地址保持完整
+func splitForwardSpec(spec string) []string {
+	var parts []string
+	var builder strings.Builder
+	inBracket := false
+	for _, char := range spec {
+		switch {
+		case char == '[':
+			inBracket = true
+		case char == ']':
+			inBracket = false
+		case char == ':' && !inBracket:
+			parts = append(parts, builder.String())
+			builder.Reset()
+		default:
+			builder.WriteRune(char)
+		}
+	}
+	return append(parts, builder.String())
+}
+
+func isValidPort(port string) bool {
+	value, err := strconv.Atoi(port)
+	return err == nil && value >= 0 && value <= 65535
+}
+
+func bracketIPv6(address string) string {
+	if strings.Contains(address, ":") {
+		return "[" + address + "]"
+	}
+	return address
+}
+
+// Spec 返回可被 parseForward 解析的形式
+func (forward Forward) Spec() string {
+	return forward.Kind + ":" + forward.sshArg()
+}
+
+// sshArg 返回 ssh -L/-R/-D 的参数
+func (forward Forward) sshArg() string {
+	parts := []string{forward.BindPort}
+	if forward.BindAddress != "" {
+		parts = append([]string{bracketIPv6(forward.BindAddress)}, parts...)
+	}
+	if forward.Kind != "D" {
+		parts = append(parts, bracketIPv6(forward.TargetHost), forward.TargetPort)
+	}
+	return strings.Join(parts, ":")
+}
+
+// listenAddress 返回本地监听地址, 远程转发在服务器上监听, 无法在本地检测
+func (forward Forward) listenAddress() (string, bool) {
+	if forward.Kind == "R" {
+		return "", false
+	}
+	host := forward.BindAddress
+	switch host {
+	case "", "*", "0.0.0.0", "localhost":
+		host = "127.0.0.1"
+	case "::":
+		host = "::1"
+	}
+	return net.JoinHostPort(host, forward.BindPort), true
+}
+
+func forwardArgs(forwards []Forward) []string {
+	var args []string
+	for _, forward := range forwards {
+		args = append(args, "-"+forward.Kind, forward.sshArg())
+	}
+	return args
+}
+
+func forwardSpecs(forwards []Forward) []string {
+	specs := make([]string, 0, len(forwards))
+	for _, forward := range forwards {
+		specs = append(specs, forward.Spec())
+	}
+	return specs
+}
+
+type forwardPresetDocument struct {
+	Preset []forwardPreset `toml:"preset"`
+}
+
+// forwardPreset 为按主机预设的转发, Host 为别名或主机名的模式, 规则与主机模式参数一致
+type forwardPreset struct {
+	Host     string   `toml:"host"`
+	Forwards []string `toml:"forwards"`
+}
+
+// loadForwardPresets 读取 forwards.toml, 文件不存在时返回空列表
+func loadForwardPresets() ([]forwardPreset, error) {
+	path, err := configFilePath("forwards.toml")
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("读取端口转发预设失败: %s\n%w", path, err)
+	}
+	var document forwardPresetDocument
+	if err := toml.Unmarshal(data, &document); err != nil {
+		return nil, fmt.Errorf("解析端口转发预设失败: %s\n%w", path, err)
+	}
+	for _, preset := range document.Preset {
+		if preset.Host == "" {
+			return nil, fmt.Errorf("解析端口转发预设失败: %s\n预设缺少 host", path)
+		}
+		if _, err := parseForwards(preset.Forwards); err != nil {
+			return nil, fmt.Errorf("解析端口转发预设失败: %s\n%w", path, err)
+		}
+	}
+	return document.Preset, nil
+}
+
+// presetForwards 返回全部匹配主机的预设转发, 按文件中的顺序合并
+func presetForwards(presets []forwardPreset, host Host) []string {
+	var specs []string
+	for _, preset := range presets {
+		if hostMatchesPattern(host, preset.Host) {
+			specs = append(specs, preset.Forwards...)
+		}
+	}
+	return specs
+}
+
+// runTunnel 以 -N 保持转发, 断开后按指数退避重连, 直到用户中断
+func (executor *Executor) runTunnel(args []string, forwards []Forward) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	inProgressColor := color.New(color.FgCyan, color.Bold)
+	warningColor := color.New(color.FgYellow, color.Bold)
+	tunnelArgs := append(slices.Clone(tunnelSSHOptions), args...)
+	executor.logger.PrintfOut(logging.LogModeAppend, true, "ssh %s", shellJoin(tunnelArgs))
+	if executor.DryRun {
+		return nil
+	}
+	skipColor := color.New(color.FgYellow, color.Bold)
+	for _, forward := range forwards {
+		if _, ok := forward.listenAddress(); !ok {
+			executor.logger.PrintfOut(logging.LogModeAppend, true, "%s %s 在远程监听, 无法在本地检测", skipColor.Sprint("[-]"), forward.Spec())
+		}
+	}
+	backoff := tunnelMinBackoff
+	for {
+		startTime := time.Now()
+		cmd := exec.Command("ssh", tunnelArgs...)
+		cmd.Stdin = os.Stdin
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Start(); err != nil {
+			return fmt.Errorf("启动 ssh 失败\n%w", err)
+		}
+		// done 在 ssh 退出后关闭, 之后 err 可读
+		var err error
+		done := make(chan struct{})
+		go func() {
+			err = cmd.Wait()
+			close(done)
+		}()
+		go executor.reportListening(ctx, forwards, done)
+
+		select {
+		case <-done:
+		case <-ctx.Done():
+			// Windows 不支持发送 SIGTERM, 此时强制结束, 否则会一直等待 ssh 退出
+			if err := cmd.Process.Signal(syscall.SIGTERM); err != nil {
+				_ = cmd.Process.Kill()
+			}
+			<-done
+			return nil
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+		if time.Since(startTime) >= tunnelStableDuration {
+			backoff = tunnelMinBackoff
+		}
+		status := "正常退出"
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			status = fmt.Sprintf("退出码 %d", exitErr.ExitCode())
+		} else if err != nil {
+			status = err.Error()
+		}
+		executor.logger.PrintfErr(logging.LogModeAppend, true, "%s 连接已断开 (%s), %s 后重连", warningColor.Sprint("[!]"), status, backoff)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return nil
+		}
+		backoff = min(backoff*2, tunnelMaxBackoff)
+		executor.logger.PrintfOut(logging.LogModeAppend, true, "%s 正在重连", inProgressColor.Sprint("[>]"))
+	}
+}
+
+// reportListening 轮询本地转发端口, 开始监听时输出提示, ssh 退出后停止
+func (executor *Executor) reportListening(ctx context.Context, forwards []Forward, done <-chan struct{}) {
+	successColor := color.New(color.FgGreen, color.Bold)
+	warningColor := color.New(color.FgYellow, color.Bold)
+	pending := make(map[int]string)
+	for i, forward := range forwards {
+		if address, ok := forward.listenAddress(); ok {
+			pending[i] = address
+		}
+	}
+	deadline := time.Now().Add(forwardListenTimeout)
+	ticker := time.NewTicker(200 * time.Millisecond)
+	defer ticker.Stop()
+	for len(pending) > 0 && time.Now().Before(deadline) {
+		select {
+		case <-ctx.Done():
+			return
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+		for i, address := range pending {
+			if !isListening(address) {
+				continue
+			}
+			executor.logger.PrintfOut(logging.LogModeAppend, true, "%s %s 正在监听 %s", successColor.Sprint("[O]"), forwards[i].Spec(), address)
+			delete(pending, i)
+		}
+	}
+	for i, address := range pending {
+		executor.logger.PrintfErr(logging.LogModeAppend, true, "%s %s 在 %s 内未开始监听 %s", warningColor.Sprint("[!]"), forwards[i].Spec(), forwardListenTimeout, address)
+	}
+}
@@ -0,0 +1,113 @@
+package ssh
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/sahilm/fuzzy"
+)
+
+// 表格列下标, 与 hostRow 的顺序一致
+const (
+	hostColumnAlias = iota
+	hostColumnUser
+	hostColumnHostname
+	hostColumnPort
+	// 可达性由选择器在后台探测后填充, hostRow 中为空
+	hostColumnProbe
+	hostColumnProxyJump
+	hostColumnSource
+	hostColumnTags
+	hostColumnKnown
+	hostColumnKeyType
+	hostColumnFingerprint
+	hostColumnCount
+)
+
+// 字段限定查询的前缀, 如 user:deploy 或 src:known_hosts
+var hostQueryScopes = map[string]int{
+	"alias":     hostColumnAlias,
+	"user":      hostColumnUser,
+	"host":      hostColumnHostname,
+	"hostname":  hostColumnHostname,
+	"port":      hostColumnPort,
+	"jump":      hostColumnProxyJump,
+	"proxyjump": hostColumnProxyJump,
+	"src":       hostColumnSource,
+	"source":    hostColumnSource,
+	"tag":       hostColumnTags,
+	"tags":      hostColumnTags,
+	"key":       hostColumnKeyType,
+	"fp":        hostColumnFingerprint,
+}
+
+// 未限定字段的查询词在这些列中取最高分
+var hostQueryDefaultColumns = []int{hostColumnAlias, hostColumnHostname, hostColumnUser, hostColumnProxyJump, hostColumnSource, hostColumnTags}
+
+type hostQueryTerm struct {
+	Columns []int
+	Pattern string
+}
+
+type hostMatch struct {
+	Host  Host
+	Score int
+	// 按列记录匹配字符的字节下标, 用于高亮
+	Highlights [hostColumnCount][]int
+}
+
+// parseHostQuery 按空白拆分查询词, 已知前缀的查询词只匹配对应列
+func parseHostQuery(query string) []hostQueryTerm {
+	var terms []hostQueryTerm
+	for _, field := range strings.Fields(query) {
+		if scope, pattern, ok := strings.Cut(field, ":"); ok {
+			if column, known := hostQueryScopes[strings.ToLower(scope)]; known {
+				if pattern != "" {
+					terms = append(terms, hostQueryTerm{Columns: []int{column}, Pattern: pattern})
+				}
+				continue
+			}
+		}
+		terms = append(terms, hostQueryTerm{Columns: hostQueryDefaultColumns, Pattern: field})
+	}
+	return terms
+}
+
+// matchHosts 返回全部查询词均匹配的主机, 按得分从高到低排序, 同分保持原有顺序
+func matchHosts(hosts []Host, query string) []hostMatch {
+	terms := parseHostQuery(query)
+	matches := make([]hostMatch, 0, len(hosts))
+	for _, host := range hosts {
+		match := hostMatch{Host: host}
+		row := hostRow(host)
+		matched := true
+		for _, term := range terms {
+			bestColumn, bestScore := -1, 0
+			var bestIndexes []int
+			for _, column := range term.Columns {
+				results := fuzzy.Find(term.Pattern, []string{row[column]})
+				if len(results) == 0 {
+					continue
+				}
+				if bestColumn == -1 || results[0].Score > bestScore {
+					bestColumn, bestScore, bestIndexes = column, results[0].Score, results[0].MatchedIndexes
+				}
+			}
+			if bestColumn == -1 {
+				matched = false
+				break
+			}
+			match.Score += bestScore
+			match.Highlights[bestColumn] = append(match.Highlights[bestColumn], bestIndexes...)
+		}
+		if matched {
+			matches = append(matches, match)
+		}
+	}
+	if len(terms) > 0 {
+		sort.SliceStable(matches, func(i, j int) bool {
+			return matches[i].Score > matches[j].Score
+		})
+	}
+	return matches
+}
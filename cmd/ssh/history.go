@@ -0,0 +1,202 @@
+package ssh
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 历史文件保留的最大记录数, 超出时丢弃最早的记录
+const historyMaxEntries = 2000
+
+// HistoryEntry 为一次 runSSH 调用的完整参数
+type HistoryEntry struct {
+	HostStr           string    `json:"host"`
+	ProxyJump         string    `json:"proxy_jump,omitempty"`
+	SshOptions        []string  `json:"ssh_options,omitempty"`
+	RemoteCommandArgs []string  `json:"remote_command,omitempty"`
+	Forwards          []string  `json:"forwards,omitempty"`
+	Time              time.Time `json:"time"`
+}
+
+// key 用于合并相同命令行的多次调用
+func (entry HistoryEntry) key() string {
+	return strings.Join([]string{
+		entry.HostStr,
+		entry.ProxyJump,
+		strings.Join(entry.SshOptions, "\x00"),
+		strings.Join(entry.RemoteCommandArgs, "\x00"),
+		strings.Join(entry.Forwards, "\x00"),
+	}, "\x01")
+}
+
+// loadHistory 读取历史记录, 文件不存在时返回空列表, 无法解析的行被忽略
+func loadHistory() ([]HistoryEntry, error) {
+	path, err := historyPath()
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("读取历史记录失败: %s\n%w", path, err)
+	}
+	defer func(file *os.File) {
+		_ = file.Close()
+	}(file)
+	var entries []HistoryEntry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var entry HistoryEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil || entry.HostStr == "" {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取历史记录失败: %s\n%w", path, err)
+	}
+	return entries, nil
+}
+
+// appendHistory 追加一条记录, 超出上限时重写文件只保留最近的记录
+func appendHistory(entry HistoryEntry) error {
+	path, err := historyPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("创建历史记录目录失败\n%w", err)
+	}
+	entries, err := loadHistory()
+	if err != nil {
+		return err
+	}
+	if len(entries) < historyMaxEntries {
+		line, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+		if err != nil {
+			return fmt.Errorf("写入历史记录失败: %s\n%w", path, err)
+		}
+		if _, err := file.Write(append(line, '\n')); err != nil {
+			_ = file.Close()
+			return fmt.Errorf("写入历史记录失败: %s\n%w", path, err)
+		}
+		return file.Close()
+	}
+	entries = append(entries[len(entries)-historyMaxEntries+1:], entry)
+	var buffer bytes.Buffer
+	for _, entry := range entries {
+		line, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		buffer.Write(append(line, '\n'))
+	}
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, buffer.Bytes(), 0o600); err != nil {
+		return fmt.Errorf("写入历史记录失败: %s\n%w", tmpPath, err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("写入历史记录失败: %s\n%w", path, err)
+	}
+	return nil
+}
+
+// frecencyWeight 按距今时间衰减的单次调用权重
+func frecencyWeight(age time.Duration) float64 {
+	switch {
+	case age < 4*time.Hour:
+		return 100
+	case age < 24*time.Hour:
+		return 80
+	case age < 7*24*time.Hour:
+		return 60
+	case age < 30*24*time.Hour:
+		return 40
+	case age < 90*24*time.Hour:
+		return 20
+	}
+	return 10
+}
+
+// historyHosts 合并相同命令行的记录, 按 frecency 从高到低转换为 history 来源的主机
+func historyHosts(entries []HistoryEntry, knownHosts []Host, now time.Time) []Host {
+	scores := make(map[string]float64)
+	latest := make(map[string]HistoryEntry)
+	for _, entry := range entries {
+		key := entry.key()
+		scores[key] += frecencyWeight(now.Sub(entry.Time))
+		if last, ok := latest[key]; !ok || entry.Time.After(last.Time) {
+			latest[key] = entry
+		}
+	}
+	keys := make([]string, 0, len(latest))
+	for key := range latest {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if scores[keys[i]] != scores[keys[j]] {
+			return scores[keys[i]] > scores[keys[j]]
+		}
+		return latest[keys[i]].Time.After(latest[keys[j]].Time)
+	})
+	hosts := make([]Host, 0, len(keys))
+	for _, key := range keys {
+		entry := latest[key]
+		usr, server, port := parseHostString(entry.HostStr)
+		host := Host{
+			// 别名保持为 HostStr, 完整命令行通过 Label 展示
+			Alias:     entry.HostStr,
+			Hostname:  server,
+			Port:      port,
+			User:      usr,
+			ProxyJump: entry.ProxyJump,
+			Source:    "history",
+			History:   &entry,
+		}
+		// HostStr 为别名时沿用已知主机的信息
+		if index := slices.IndexFunc(knownHosts, func(known Host) bool { return known.Alias == entry.HostStr }); index != -1 {
+			known := knownHosts[index]
+			host.Hostname = known.Hostname
+			host.Port = cmp.Or(port, known.Port)
+			host.User = cmp.Or(usr, known.User)
+			host.ProxyJump = cmp.Or(entry.ProxyJump, known.ProxyJump)
+			host.Tags, host.Note = known.Tags, known.Note
+		}
+		hosts = append(hosts, host)
+	}
+	return hosts
+}
+
+// Command 返回历史记录的可读命令行, 用于表格展示与过滤
+func (entry HistoryEntry) Command() string {
+	parts := []string{entry.HostStr}
+	for _, spec := range entry.Forwards {
+		if forward, err := parseForward(spec); err == nil {
+			parts = append(parts, "-"+forward.Kind, forward.sshArg())
+		}
+	}
+	parts = append(parts, entry.SshOptions...)
+	command := shellJoin(parts)
+	// 远程命令保留原始引号
+	if len(entry.RemoteCommandArgs) > 0 {
+		command += " -- " + strings.Join(entry.RemoteCommandArgs, " ")
+	}
+	return command
+}
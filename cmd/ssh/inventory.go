@@ -0,0 +1,182 @@
+package ssh
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// 依次查找的清单文件, 均位于 ~/.config/trance/ssh
+var inventoryFileNames = []string{"inventory.toml", "inventory.yaml", "inventory.yml"}
+
+type inventoryDocument struct {
+	Host []inventoryEntry `toml:"host" yaml:"hosts"`
+	// Ansible 清单文件或目录, 相对路径基于配置目录
+	AnsibleInventories []string `toml:"ansible_inventories" yaml:"ansible_inventories"`
+}
+
+// inventoryEntry 为清单中的一个主机, Alias 为空时使用 Hostname
+// Tags 可以是 ["web", "env=prod"] 形式的列表, 也可以是 { env = "prod" } 形式的表
+type inventoryEntry struct {
+	Alias     string `toml:"alias" yaml:"alias"`
+	Hostname  string `toml:"hostname" yaml:"hostname"`
+	User      string `toml:"user" yaml:"user"`
+	Port      any    `toml:"port" yaml:"port"`
+	ProxyJump string `toml:"proxy_jump" yaml:"proxy_jump"`
+	Tags      any    `toml:"tags" yaml:"tags"`
+	Note      string `toml:"note" yaml:"note"`
+}
+
+// loadInventory 读取清单文件及其中配置的 Ansible 清单, 文件均不存在时返回空列表
+// Ansible 清单出错时仍返回其余主机
+func loadInventory() ([]Host, error) {
+	var hosts []Host
+	var ansiblePaths []string
+	configDir := ""
+	for _, name := range inventoryFileNames {
+		path, err := configFilePath(name)
+		if err != nil {
+			return nil, err
+		}
+		configDir = filepath.Dir(path)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, fmt.Errorf("读取主机清单失败: %s\n%w", path, err)
+		}
+		var document inventoryDocument
+		if filepath.Ext(path) == ".toml" {
+			err = toml.Unmarshal(data, &document)
+		} else {
+			err = yaml.Unmarshal(data, &document)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("解析主机清单失败: %s\n%w", path, err)
+		}
+		for i, entry := range document.Host {
+			host, err := entry.toHost()
+			if err != nil {
+				return nil, fmt.Errorf("解析主机清单失败: %s 第 %d 个主机\n%w", path, i+1, err)
+			}
+			hosts = append(hosts, host)
+		}
+		ansiblePaths = append(ansiblePaths, document.AnsibleInventories...)
+	}
+	ansibleHosts, err := loadAnsibleInventories(ansiblePaths, configDir)
+	return append(hosts, ansibleHosts...), err
+}
+
+func (entry inventoryEntry) toHost() (Host, error) {
+	if entry.Hostname == "" && entry.Alias == "" {
+		return Host{}, fmt.Errorf("缺少 hostname")
+	}
+	port, err := inventoryPort(entry.Port)
+	if err != nil {
+		return Host{}, err
+	}
+	tags, err := inventoryTags(entry.Tags)
+	if err != nil {
+		return Host{}, err
+	}
+	return Host{
+		Alias:     cmp.Or(entry.Alias, entry.Hostname),
+		Hostname:  cmp.Or(entry.Hostname, entry.Alias),
+		Port:      port,
+		User:      entry.User,
+		ProxyJump: entry.ProxyJump,
+		Source:    "inventory",
+		Tags:      tags,
+		Note:      strings.TrimSpace(entry.Note),
+	}, nil
+}
+
+// inventoryPort 接受整数或字符串形式的端口
+func inventoryPort(value any) (string, error) {
+	var port string
+	switch value := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		port = value
+	case int:
+		port = strconv.Itoa(value)
+	case int64:
+		port = strconv.FormatInt(value, 10)
+	default:
+		return "", fmt.Errorf("端口格式无效: %v", value)
+	}
+	if !isValidPort(port) {
+		return "", fmt.Errorf("端口格式无效: %v", value)
+	}
+	return port, nil
+}
+
+// inventoryTags 将列表或表形式的标签规范化为 label 与 key=value, 表按键排序
+func inventoryTags(value any) ([]string, error) {
+	var tags []string
+	switch value := value.(type) {
+	case nil:
+	case []any:
+		for _, item := range value {
+			tag, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("标签格式无效: %v", item)
+			}
+			tags = append(tags, tag)
+		}
+	case map[string]any:
+		for key, item := range value {
+			tags = append(tags, fmt.Sprintf("%s=%v", key, item))
+		}
+		sort.Strings(tags)
+	default:
+		return nil, fmt.Errorf("标签格式无效: %v", value)
+	}
+	return tags, nil
+}
+
+// addInventoryHosts 将清单合并到主机列表, 主机名或别名与 ssh_config 主机相同时只补充标签与备注
+func addInventoryHosts(inventory []Host, hostMap map[string]Host) {
+	for _, entry := range inventory {
+		merged := false
+		for alias, host := range hostMap {
+			if host.Source != "ssh_config" || (!strings.EqualFold(host.Hostname, entry.Hostname) && host.Alias != entry.Alias) {
+				continue
+			}
+			host.Tags = mergeTags(host.Tags, entry.Tags)
+			host.Note = cmp.Or(host.Note, entry.Note)
+			hostMap[alias] = host
+			merged = true
+		}
+		if merged {
+			continue
+		}
+		if existing, ok := hostMap[entry.Alias]; ok {
+			// 同名的清单主机合并标签
+			existing.Tags = mergeTags(existing.Tags, entry.Tags)
+			existing.Note = cmp.Or(existing.Note, entry.Note)
+			hostMap[entry.Alias] = existing
+			continue
+		}
+		hostMap[entry.Alias] = entry
+	}
+}
+
+func mergeTags(tags []string, additions []string) []string {
+	for _, tag := range additions {
+		if !slices.Contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
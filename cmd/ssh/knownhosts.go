@@ -0,0 +1,139 @@
+package ssh
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strings"
+)
+
+type knownHostEntry struct {
+	// 明文主机模式, 可包含通配符与否定
+	Patterns []string
+	// 哈希主机名 |1|salt|hash
+	Salt    []byte
+	Hash    []byte
+	KeyType string
+	Key     []byte
+}
+
+// KnownHosts 为已读取的 known_hosts 记录, 支持明文与哈希主机名
+type KnownHosts struct {
+	entries []knownHostEntry
+}
+
+// Load 读取 known_hosts 文件, 跳过 @revoked 与 @cert-authority 记录以及无法解析的行
+func (knownHosts *KnownHosts) Load(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("读取 known_hosts 文件失败: %s\n%w", path, err)
+	}
+	defer func(file *os.File) {
+		_ = file.Close()
+	}(file)
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 3 || strings.HasPrefix(fields[0], "#") || strings.HasPrefix(fields[0], "@") {
+			continue
+		}
+		key, err := base64.StdEncoding.DecodeString(fields[2])
+		if err != nil {
+			continue
+		}
+		entry := knownHostEntry{KeyType: fields[1], Key: key}
+		if strings.HasPrefix(fields[0], "|1|") {
+			parts := strings.Split(fields[0], "|")
+			if len(parts) != 4 {
+				continue
+			}
+			salt, saltErr := base64.StdEncoding.DecodeString(parts[2])
+			hash, hashErr := base64.StdEncoding.DecodeString(parts[3])
+			if saltErr != nil || hashErr != nil {
+				continue
+			}
+			entry.Salt, entry.Hash = salt, hash
+		} else {
+			entry.Patterns = strings.Split(fields[0], ",")
+		}
+		knownHosts.entries = append(knownHosts.entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("读取 known_hosts 文件失败: %s\n%w", path, err)
+	}
+	return nil
+}
+
+// Lookup 查找主机的首个公钥, 非 22 端口按 [host]:port 匹配
+func (knownHosts *KnownHosts) Lookup(hostname string, port string) (keyType string, fingerprint string, ok bool) {
+	if knownHosts == nil || hostname == "" {
+		return "", "", false
+	}
+	name := strings.ToLower(hostname)
+	if port != "" && port != "22" {
+		name = "[" + name + "]:" + port
+	}
+	for _, entry := range knownHosts.entries {
+		if entry.matches(name) {
+			return entry.KeyType, keyFingerprint(entry.Key), true
+		}
+	}
+	return "", "", false
+}
+
+// Annotate 为主机填充 known_hosts 中的公钥信息
+func (knownHosts *KnownHosts) Annotate(host *Host) {
+	keyType, fingerprint, ok := knownHosts.Lookup(host.Hostname, host.Port)
+	host.Known, host.KeyType, host.Fingerprint = ok, keyType, fingerprint
+}
+
+// addPlainHosts 将明文主机名加入主机列表, 带通配符的模式无法作为主机使用
+func (knownHosts *KnownHosts) addPlainHosts(hostMap map[string]Host) {
+	for _, entry := range knownHosts.entries {
+		for _, pattern := range entry.Patterns {
+			if strings.HasPrefix(pattern, "!") || strings.ContainsAny(pattern, "*?") {
+				continue
+			}
+			hostname := pattern
+			port := ""
+			// 非 22 端口的格式为 [hostname]:port
+			if strings.HasPrefix(hostname, "[") && strings.Contains(hostname, "]:") {
+				parts := strings.SplitN(strings.TrimPrefix(hostname, "["), "]:", 2)
+				if len(parts) == 2 {
+					hostname = parts[0]
+					port = parts[1]
+				}
+			}
+			if _, ok := hostMap[hostname]; !ok {
+				hostMap[hostname] = Host{
+					Alias:    hostname,
+					Hostname: hostname,
+					Port:     port,
+					Source:   "known_hosts",
+				}
+			}
+		}
+	}
+}
+
+func (entry knownHostEntry) matches(name string) bool {
+	if entry.Hash != nil {
+		mac := hmac.New(sha1.New, entry.Salt)
+		mac.Write([]byte(name))
+		return bytes.Equal(mac.Sum(nil), entry.Hash)
+	}
+	return matchHostPatterns(name, entry.Patterns)
+}
+
+// keyFingerprint 返回与 ssh-keygen -l 一致的 SHA256 指纹
+func keyFingerprint(key []byte) string {
+	sum := sha256.Sum256(key)
+	return "SHA256:" + base64.RawStdEncoding.EncodeToString(sum[:])
+}
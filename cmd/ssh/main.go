@@ -9,9 +9,10 @@ import (
 var executor = &Executor{}
 
 var cmd = &cobra.Command{
-	Use:   "ssh [wrapper-flags] [host] [-- ssh-options] [-- remote-command [arguments]]",
+	Use:   "ssh [wrapper-flags] [host|host-pattern] [-- ssh-options] [-- remote-command [arguments]]",
 	Short: "Connect to an SSH host, with an interactive selector",
-	Long:  "",
+	Long: "The words cp and tunnel are reserved for the subcommands below, so a host whose alias is cp or tunnel " +
+		"can not be passed as the host argument. Select it in the selector instead, or pass it with a user such as user@cp.",
 	Run: func(cmd *cobra.Command, args []string) {
 		executor.Run(cmd, args)
 	},
@@ -20,8 +21,17 @@ var cmd = &cobra.Command{
 
 func Register(parentCmd *cobra.Command) {
 	cmd.Flags().BoolVarP(&executor.DryRun, "dry-run", "n", false, "Print the commands that would be executed, but do not execute them")
-	cmd.Flags().BoolVarP(&executor.Proxy, "proxy", "p", false, "Create a SOCKS proxy on 0.0.0.0:1080")
+	cmd.Flags().BoolVarP(&executor.Proxy, "proxy", "p", false, "Create a SOCKS proxy on 0.0.0.0:1080, same as --forward D:0.0.0.0:1080 without a remote shell")
+	cmd.Flags().StringArrayVarP(&executor.Forwards, "forward", "f", nil, "Add a port forward as <L|R|D>:[bind_address:]port[:host:hostport], e.g. L:5432:db:5432 (repeatable, presets in ~/.config/trance/ssh/forwards.toml)")
+	cmd.Flags().BoolVarP(&executor.KeepAlive, "keep-alive", "k", false, "Keep the forwards open without a remote shell, reconnect when the connection drops and report when local ports are listening")
+	cmd.Flags().BoolVarP(&executor.Resolve, "resolve", "g", false, "Show the effective configuration from ssh -G for the selected host (toggle with ctrl+g)")
+	cmd.Flags().BoolVar(&executor.NoProbe, "no-probe", false, "Do not probe TCP reachability of the hosts visible in the selector, hosts behind a ProxyJump are never probed")
+	cmd.Flags().BoolVar(&executor.ProbeBanner, "banner", false, "Also read the SSH banner of reachable hosts, shown in the ssh -G details")
+	cmd.Flags().IntVarP(&executor.Jobs, "jobs", "j", 8, "Number of hosts to run the remote command on concurrently when several hosts are selected (mark with ctrl+t, or pass a host pattern such as 'web-*' or 'tag:role=web')")
+	cmd.Flags().DurationVarP(&executor.Timeout, "timeout", "t", 0, "Kill the remote command on a host after this duration when running on several hosts, 0 means no limit")
 	cmd.DisableFlagsInUseLine = true
+	registerTunnel(cmd)
+	registerCopy(cmd)
 	if system.IsCommandAvailable("ssh") {
 		parentCmd.AddCommand(cmd)
 	}
@@ -0,0 +1,29 @@
+package ssh
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// historyPath 返回 $XDG_STATE_HOME/trance/ssh/history.jsonl, 未设置时使用 ~/.local/state
+func historyPath() (string, error) {
+	return xdgPath("XDG_STATE_HOME", filepath.Join(".local", "state"), "history.jsonl")
+}
+
+// configFilePath 返回 $XDG_CONFIG_HOME/trance/ssh 下的配置文件路径, 未设置时使用 ~/.config
+func configFilePath(name string) (string, error) {
+	return xdgPath("XDG_CONFIG_HOME", ".config", name)
+}
+
+func xdgPath(env string, fallback string, name string) (string, error) {
+	baseDir := os.Getenv(env)
+	if baseDir == "" {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("获取用户主目录失败\n%w", err)
+		}
+		baseDir = filepath.Join(homeDir, fallback)
+	}
+	return filepath.Join(baseDir, "trance", "ssh", name), nil
+}
@@ -0,0 +1,123 @@
+package ssh
+
+import (
+	"bufio"
+	"cmp"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+const (
+	// 单个主机的连接与读取 banner 超时
+	probeTimeout = 2 * time.Second
+	// 探测结果的有效期, 过期后主机再次可见时重新探测
+	probeTTL = 30 * time.Second
+)
+
+// 同时进行的探测数上限, 避免快速滚动时创建过多连接
+var probeSemaphore = make(chan struct{}, 16)
+
+type probeResult struct {
+	Pending   bool
+	Reachable bool
+	Latency   time.Duration
+	// SSH 服务端标识, 如 SSH-2.0-OpenSSH_9.6, 仅在读取 banner 时填充
+	Banner string
+	Err    error
+	Time   time.Time
+}
+
+type probeMsg struct {
+	address string
+	result  probeResult
+}
+
+// probeAddress 返回探测的 host:port, 经由跳板机连接的主机无法直接探测
+func probeAddress(host Host) (string, bool) {
+	if host.ProxyJump != "" || host.Hostname == "" {
+		return "", false
+	}
+	return net.JoinHostPort(host.Hostname, cmp.Or(host.Port, "22")), true
+}
+
+// probeHostCmd 在后台探测 TCP 可达性, 结果以 probeMsg 返回
+func probeHostCmd(address string, readBanner bool) tea.Cmd {
+	return func() tea.Msg {
+		probeSemaphore <- struct{}{}
+		defer func() { <-probeSemaphore }()
+		return probeMsg{address: address, result: probeHost(address, readBanner)}
+	}
+}
+
+func probeHost(address string, readBanner bool) probeResult {
+	startTime := time.Now()
+	conn, err := net.DialTimeout("tcp", address, probeTimeout)
+	result := probeResult{Time: time.Now()}
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+	result.Reachable = true
+	result.Latency = time.Since(startTime)
+	if readBanner {
+		_ = conn.SetReadDeadline(time.Now().Add(probeTimeout))
+		// 服务端可能先发送其他行, 以 SSH- 开头的行为标识
+		reader := bufio.NewReader(conn)
+		for range 5 {
+			line, err := reader.ReadString('\n')
+			line = strings.TrimSpace(line)
+			if strings.HasPrefix(line, "SSH-") {
+				result.Banner = line
+				break
+			}
+			if err != nil {
+				break
+			}
+		}
+	}
+	return result
+}
+
+// Summary 返回表格中显示的简短状态
+func (result *probeResult) Summary() string {
+	switch {
+	case result.Pending:
+		return "..."
+	case result.Reachable:
+		return fmt.Sprintf("up %dms", result.Latency.Milliseconds())
+	case errors.Is(result.Err, syscall.ECONNREFUSED):
+		return "refused"
+	}
+	var netErr net.Error
+	if errors.As(result.Err, &netErr) && netErr.Timeout() {
+		return "timeout"
+	}
+	return "down"
+}
+
+// Detail 返回详情面板中的完整状态
+func (result *probeResult) Detail() string {
+	switch {
+	case result.Pending:
+		return "probing..."
+	case result.Reachable:
+		return strings.TrimSpace(fmt.Sprintf("reachable in %dms %s", result.Latency.Milliseconds(), result.Banner))
+	case result.Err != nil:
+		message := result.Err.Error()
+		// 去掉 dial tcp 1.2.3.4:22: 前缀
+		if index := strings.LastIndex(message, ": "); index != -1 {
+			message = message[index+2:]
+		}
+		return "unreachable: " + message
+	}
+	return ""
+}
@@ -0,0 +1,37 @@
+//go:build !windows
+
+package ssh
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// detachedProcAttr 使后台进程脱离当前终端的会话, 关闭终端后继续运行
+func detachedProcAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{Setsid: true}
+}
+
+// tryLockFile 以非阻塞方式获取文件的排他锁, 已被其他进程持有时 acquired 为 false, 锁在文件关闭或进程退出时释放
+func tryLockFile(path string) (file *os.File, acquired bool, err error) {
+	file, err = os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, false, err
+	}
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		_ = file.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	return file, true, nil
+}
+
+// 后台进程收到 SIGTERM 后自行停止 ssh
+const supervisorStopsChild = true
+
+func terminateProcess(pid int) error {
+	return syscall.Kill(pid, syscall.SIGTERM)
+}
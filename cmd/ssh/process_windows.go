@@ -0,0 +1,49 @@
+//go:build windows
+
+package ssh
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// 文件已被其他进程以独占方式打开
+const errorSharingViolation = syscall.Errno(32)
+
+// detachedProcAttr 使后台进程不接收当前控制台的 Ctrl+C
+func detachedProcAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP}
+}
+
+// tryLockFile 以不共享的方式打开文件作为排他锁, 已被其他进程持有时 acquired 为 false, 锁在文件关闭或进程退出时释放
+func tryLockFile(path string) (file *os.File, acquired bool, err error) {
+	pathPtr, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return nil, false, err
+	}
+	handle, err := syscall.CreateFile(pathPtr, syscall.GENERIC_READ|syscall.GENERIC_WRITE, 0, nil, syscall.OPEN_ALWAYS, syscall.FILE_ATTRIBUTE_NORMAL, 0)
+	if err != nil {
+		if errors.Is(err, errorSharingViolation) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	return os.NewFile(uintptr(handle), path), true, nil
+}
+
+// Windows 无法向后台进程发送 SIGTERM, 只能强制结束, 其启动的 ssh 需由调用方单独结束
+const supervisorStopsChild = false
+
+// terminateProcess 强制结束进程并等待其退出
+func terminateProcess(pid int) error {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+	if err := process.Kill(); err != nil {
+		return err
+	}
+	_, err = process.Wait()
+	return err
+}
@@ -0,0 +1,103 @@
+package ssh
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// ssh -G 可能执行 Match exec, 需限制等待时间
+const resolveTimeout = 5 * time.Second
+
+// ResolvedConfig 为 ssh -G 输出的生效配置, 键为小写配置项名称, 可重复的配置项保留全部值
+type ResolvedConfig map[string][]string
+
+func (config ResolvedConfig) Get(key string) string {
+	if values := config[key]; len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+type resolveResult struct {
+	Config  ResolvedConfig
+	Err     error
+	Loading bool
+}
+
+type resolveMsg struct {
+	key    string
+	config ResolvedConfig
+	err    error
+}
+
+// resolveHostCmd 在后台执行 ssh -G, 结果以 resolveMsg 返回
+func resolveHostCmd(host Host) tea.Cmd {
+	return func() tea.Msg {
+		config, err := resolveHostConfig(host)
+		return resolveMsg{key: resolveKey(host), config: config, err: err}
+	}
+}
+
+// resolveKey 返回解析结果的缓存键, ssh -G 参数相同的主机结果相同
+// 不同来源的主机可能同名, 如历史记录中的 HostStr 与 ssh_config 别名, 不能以 Alias 区分
+func resolveKey(host Host) string {
+	return strings.Join(resolveArgs(host), "\x00")
+}
+
+// resolveArgs 返回解析主机使用的 ssh -G 参数
+func resolveArgs(host Host) []string {
+	args := []string{"-G"}
+	target := host.Alias
+	switch {
+	// 历史记录按当时输入的 HostStr 解析, 其中可能是别名
+	case host.History != nil:
+		usr, server, port := parseHostString(host.History.HostStr)
+		target = server
+		if port != "" {
+			args = append(args, "-p", port)
+		}
+		if usr != "" {
+			args = append(args, "-l", usr)
+		}
+	// 非 ssh_config 来源的主机没有别名配置, 需显式传入端口与用户
+	case host.Source != "ssh_config":
+		if host.Port != "" {
+			args = append(args, "-p", host.Port)
+		}
+		if host.User != "" {
+			args = append(args, "-l", host.User)
+		}
+	}
+	return append(args, "--", target)
+}
+
+func resolveHostConfig(host Host) (ResolvedConfig, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), resolveTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "ssh", resolveArgs(host)...)
+	var cmdErr bytes.Buffer
+	cmd.Stderr = &cmdErr
+	output, err := cmd.Output()
+	if err != nil {
+		if message := strings.TrimSpace(cmdErr.String()); message != "" {
+			return nil, fmt.Errorf("执行 ssh -G 失败: %s", message)
+		}
+		return nil, fmt.Errorf("执行 ssh -G 失败\n%w", err)
+	}
+	config := make(ResolvedConfig)
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		key, value, _ := strings.Cut(scanner.Text(), " ")
+		if key != "" {
+			config[key] = append(config[key], value)
+		}
+	}
+	return config, nil
+}
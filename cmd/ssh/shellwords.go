@@ -0,0 +1,110 @@
+package ssh
+
+import (
+	"fmt"
+	"strings"
+)
+
+// splitShellWords 按 POSIX shell 规则拆分单词, 支持单引号、双引号与反斜杠转义, 不展开变量与通配符
+func splitShellWords(input string) ([]string, error) {
+	var words []string
+	var builder strings.Builder
+	// 引号内的空字符串同样是一个单词
+	hasWord := false
+	runes := []rune(input)
+	for i := 0; i < len(runes); i++ {
+		char := runes[i]
+		switch {
+		case char == ' ' || char == '\t' || char == '\n':
+			if hasWord {
+				words = append(words, builder.String())
+				builder.Reset()
+				hasWord = false
+			}
+		case char == '\\':
+			if i+1 >= len(runes) {
+				return nil, fmt.Errorf("末尾的反斜杠无效")
+			}
+			i++
+			// 反斜杠加换行为续行
+			if runes[i] != '\n' {
+				builder.WriteRune(runes[i])
+				hasWord = true
+			}
+		case char == '\'':
+			end := i + 1
+			for end < len(runes) && runes[end] != '\'' {
+				end++
+			}
+			if end >= len(runes) {
+				return nil, fmt.Errorf("单引号未闭合")
+			}
+			builder.WriteString(string(runes[i+1 : end]))
+			hasWord = true
+			i = end
+		case char == '"':
+			i++
+			for ; i < len(runes) && runes[i] != '"'; i++ {
+				// 双引号内的反斜杠只转义 $ ` " \ 与换行
+				if runes[i] == '\\' && i+1 < len(runes) && strings.ContainsRune("$`\"\\\n", runes[i+1]) {
+					i++
+					if runes[i] == '\n' {
+						continue
+					}
+				}
+				builder.WriteRune(runes[i])
+			}
+			if i >= len(runes) {
+				return nil, fmt.Errorf("双引号未闭合")
+			}
+			hasWord = true
+		default:
+			builder.WriteRune(char)
+			hasWord = true
+		}
+	}
+	if hasWord {
+		words = append(words, builder.String())
+	}
+	return words, nil
+}
+
+// shellQuote 对含特殊字符的单词加单引号, 使其可在 shell 中原样使用
+func shellQuote(word string) string {
+	if word == "" {
+		return "''"
+	}
+	safe := true
+	for _, char := range word {
+		if !(char >= 'a' && char <= 'z' || char >= 'A' && char <= 'Z' || char >= '0' && char <= '9' || strings.ContainsRune("_@%+=:,./-", char)) {
+			safe = false
+			break
+		}
+	}
+	if safe {
+		return word
+	}
+	return "'" + strings.ReplaceAll(word, "'", `'\''`) + "'"
+}
+
+// shellJoin 返回可复制到 shell 中执行的命令行
+func shellJoin(words []string) string {
+	quoted := make([]string, len(words))
+	for i, word := range words {
+		quoted[i] = shellQuote(word)
+	}
+	return strings.Join(quoted, " ")
+}
+
+// parseRemoteCommand 校验远程命令的引号, 并原样作为单个参数交给 ssh
+// ssh 会将参数以空格拼接后交给远程 shell 解析, 保留原始引号才能使管道、通配符与 bash -c '...' 在远程生效
+func parseRemoteCommand(input string) ([]string, error) {
+	input = strings.TrimSpace(input)
+	if _, err := splitShellWords(input); err != nil {
+		return nil, err
+	}
+	if input == "" {
+		return nil, nil
+	}
+	return []string{input}, nil
+}
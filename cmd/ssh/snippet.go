@@ -0,0 +1,146 @@
+package ssh
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+// 命令中的参数占位符, 如 {{unit}}
+var snippetParamRe = regexp.MustCompile(`{{[\p{Zs}\t]*([\pL\pN_]+)[\p{Zs}\t]*}}`)
+
+type snippetDocument struct {
+	Snippet []snippet `toml:"snippet"`
+}
+
+// snippet 为 snippets.toml 中的一个远程命令片段, Tags 非空时只对带有匹配标签的主机可用
+type snippet struct {
+	Name        string         `toml:"name"`
+	Description string         `toml:"description"`
+	Command     string         `toml:"command"`
+	Tags        []string       `toml:"tags"`
+	Params      []snippetParam `toml:"params"`
+}
+
+// snippetParam 为命令参数, Options 非空时只能取其中的值, Optional 时可以为空
+type snippetParam struct {
+	Key      string   `toml:"key"`
+	Label    string   `toml:"label"`
+	Default  string   `toml:"default"`
+	Options  []string `toml:"options"`
+	Optional bool     `toml:"optional"`
+}
+
+// loadSnippets 读取 snippets.toml, 文件不存在时返回空列表
+func loadSnippets() ([]snippet, error) {
+	path, err := configFilePath("snippets.toml")
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("读取命令片段失败: %s\n%w", path, err)
+	}
+	var document snippetDocument
+	if err := toml.Unmarshal(data, &document); err != nil {
+		return nil, fmt.Errorf("解析命令片段失败: %s\n%w", path, err)
+	}
+	names := map[string]struct{}{}
+	for i := range document.Snippet {
+		if err := document.Snippet[i].normalize(); err != nil {
+			return nil, fmt.Errorf("解析命令片段失败: %s\n%w", path, err)
+		}
+		if _, ok := names[document.Snippet[i].Name]; ok {
+			return nil, fmt.Errorf("解析命令片段失败: %s\n片段名称重复: %s", path, document.Snippet[i].Name)
+		}
+		names[document.Snippet[i].Name] = struct{}{}
+	}
+	return document.Snippet, nil
+}
+
+// normalize 校验片段, 为命令中未声明的占位符补充参数, 参数按在命令中出现的顺序排列
+func (item *snippet) normalize() error {
+	if item.Name == "" {
+		return fmt.Errorf("片段缺少 name")
+	}
+	if strings.TrimSpace(item.Command) == "" {
+		return fmt.Errorf("片段 %s 缺少 command", item.Name)
+	}
+	// 远程命令输入框为单行, 换行会被替换为空格而改变命令含义
+	item.Command = strings.TrimSpace(item.Command)
+	if strings.ContainsAny(item.Command, "\r\n") {
+		return fmt.Errorf("片段 %s 的 command 不能包含换行, 多条命令请以 ; 或 && 连接", item.Name)
+	}
+	var placeholders []string
+	for _, match := range snippetParamRe.FindAllStringSubmatch(item.Command, -1) {
+		if !slices.Contains(placeholders, match[1]) {
+			placeholders = append(placeholders, match[1])
+		}
+	}
+	var keys []string
+	for i, param := range item.Params {
+		if param.Key == "" {
+			return fmt.Errorf("片段 %s 的参数缺少 key", item.Name)
+		}
+		if slices.Contains(keys, param.Key) {
+			return fmt.Errorf("片段 %s 的参数重复: %s", item.Name, param.Key)
+		}
+		if !slices.Contains(placeholders, param.Key) {
+			return fmt.Errorf("片段 %s 的命令中未使用参数: %s", item.Name, param.Key)
+		}
+		if len(param.Options) > 0 && param.Default != "" && !slices.Contains(param.Options, param.Default) {
+			return fmt.Errorf("片段 %s 的参数 %s 默认值不在可选值中", item.Name, param.Key)
+		}
+		if param.Label == "" {
+			item.Params[i].Label = param.Key
+		}
+		keys = append(keys, param.Key)
+	}
+	params := make([]snippetParam, 0, len(placeholders))
+	for _, key := range placeholders {
+		index := slices.Index(keys, key)
+		if index == -1 {
+			params = append(params, snippetParam{Key: key, Label: key})
+		} else {
+			params = append(params, item.Params[index])
+		}
+	}
+	item.Params = params
+	return nil
+}
+
+// appliesTo 判断片段是否可用于全部主机, 未限定标签的片段始终可用, 标签支持通配符
+func (item snippet) appliesTo(hosts []Host) bool {
+	if len(item.Tags) == 0 {
+		return true
+	}
+	if len(hosts) == 0 {
+		return false
+	}
+	pattern := "tag:" + strings.Join(item.Tags, ",tag:")
+	for _, host := range hosts {
+		if !hostMatchesPattern(host, pattern) {
+			return false
+		}
+	}
+	return true
+}
+
+// render 以参数值替换占位符, 值经过 shell 转义, 空值替换为空字符串以便省略可选参数
+func (item snippet) render(values map[string]string) string {
+	command := snippetParamRe.ReplaceAllStringFunc(item.Command, func(placeholder string) string {
+		value := values[snippetParamRe.FindStringSubmatch(placeholder)[1]]
+		if value == "" {
+			return ""
+		}
+		return shellQuote(value)
+	})
+	return strings.TrimSpace(command)
+}
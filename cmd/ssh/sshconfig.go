@@ -0,0 +1,400 @@
+package ssh
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Include 嵌套深度上限, 与 OpenSSH 一致
+const sshConfigMaxIncludeDepth = 16
+
+type sshConfigDirective struct {
+	// 小写的配置项名称
+	Key  string
+	Args []string
+}
+
+// sshConfigCondition 为一个 Host 或 Match 条件, Host 使用 HostPatterns, Match 使用 Criteria
+type sshConfigCondition struct {
+	IsMatch      bool
+	HostPatterns []string
+	Criteria     []sshMatchCriterion
+}
+
+type sshMatchCriterion struct {
+	Name   string
+	Negate bool
+	Arg    string
+}
+
+type sshConfigBlock struct {
+	// 全部条件满足时生效, 位于 Host/Match 块内的 Include 会继承外层条件
+	Conditions []sshConfigCondition
+	Directives []sshConfigDirective
+}
+
+// SSHConfig 按 OpenSSH 规则解析的配置, 多个文件按读取顺序合并, 每项取首个生效的值
+type SSHConfig struct {
+	blocks []sshConfigBlock
+	// 按出现顺序记录的具体主机别名
+	aliases []string
+	// 已读取的文件, 用于避免重复读取 config.d
+	visited  map[string]bool
+	hasFinal bool
+}
+
+type sshConfigContext struct {
+	alias     string
+	localUser string
+	final     bool
+}
+
+func NewSSHConfig() *SSHConfig {
+	return &SSHConfig{visited: make(map[string]bool)}
+}
+
+// ReadFile 读取配置文件, 相对路径的 Include 基于 baseDir 解析
+func (config *SSHConfig) ReadFile(path string, baseDir string) error {
+	return config.readFile(path, baseDir, nil, 0)
+}
+
+func (config *SSHConfig) Visited(path string) bool {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	return config.visited[absPath]
+}
+
+func (config *SSHConfig) Aliases() []string {
+	return config.aliases
+}
+
+func (config *SSHConfig) readFile(path string, baseDir string, inherited []sshConfigCondition, depth int) error {
+	if depth > sshConfigMaxIncludeDepth {
+		return fmt.Errorf("SSH 配置文件 Include 嵌套过深: %s", path)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("读取 SSH 配置文件失败: %s\n%w", path, err)
+	}
+	defer func(file *os.File) {
+		_ = file.Close()
+	}(file)
+	if absPath, err := filepath.Abs(path); err == nil {
+		config.visited[absPath] = true
+	}
+
+	current := config.newBlock(inherited)
+	scanner := bufio.NewScanner(file)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		key, args, err := splitSSHConfigLine(scanner.Text())
+		if err != nil {
+			return fmt.Errorf("解析 SSH 配置文件失败: %s:%d\n%w", path, lineNumber, err)
+		}
+		if key == "" {
+			continue
+		}
+		switch key {
+		case "host":
+			if len(args) == 0 {
+				return fmt.Errorf("解析 SSH 配置文件失败: %s:%d\nHost 缺少主机模式", path, lineNumber)
+			}
+			current = config.newBlock(append(slices.Clone(inherited), sshConfigCondition{HostPatterns: args}))
+			for _, pattern := range args {
+				if !strings.HasPrefix(pattern, "!") && !strings.ContainsAny(pattern, "*?") && !slices.Contains(config.aliases, pattern) {
+					config.aliases = append(config.aliases, pattern)
+				}
+			}
+		case "match":
+			criteria, err := parseMatchCriteria(args)
+			if err != nil {
+				return fmt.Errorf("解析 SSH 配置文件失败: %s:%d\n%w", path, lineNumber, err)
+			}
+			for _, criterion := range criteria {
+				if criterion.Name == "final" {
+					config.hasFinal = true
+				}
+			}
+			current = config.newBlock(append(slices.Clone(inherited), sshConfigCondition{IsMatch: true, Criteria: criteria}))
+		case "include":
+			conditions := config.blocks[current].Conditions
+			for _, arg := range args {
+				pattern := expandHomeDir(arg)
+				if !filepath.IsAbs(pattern) {
+					pattern = filepath.Join(baseDir, pattern)
+				}
+				// 未匹配任何文件时静默跳过, 与 OpenSSH 一致
+				matches, err := filepath.Glob(pattern)
+				if err != nil {
+					return fmt.Errorf("解析 SSH 配置文件失败: %s:%d\nInclude 路径无效: %s", path, lineNumber, arg)
+				}
+				for _, match := range matches {
+					if info, err := os.Stat(match); err != nil || info.IsDir() {
+						continue
+					}
+					if err := config.readFile(match, baseDir, conditions, depth+1); err != nil {
+						return err
+					}
+				}
+			}
+			// Include 之后的配置项仍属于当前块
+			current = config.newBlock(conditions)
+		default:
+			config.blocks[current].Directives = append(config.blocks[current].Directives, sshConfigDirective{Key: key, Args: args})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("读取 SSH 配置文件失败: %s\n%w", path, err)
+	}
+	return nil
+}
+
+func (config *SSHConfig) newBlock(conditions []sshConfigCondition) int {
+	config.blocks = append(config.blocks, sshConfigBlock{Conditions: conditions})
+	return len(config.blocks) - 1
+}
+
+// Resolve 计算别名生效的配置, 键为小写配置项名称
+func (config *SSHConfig) Resolve(alias string, localUser string) map[string]string {
+	options := make(map[string]string)
+	context := sshConfigContext{alias: alias, localUser: localUser}
+	config.apply(context, options)
+	// 存在 Match final 时与 OpenSSH 一样再处理一遍
+	if config.hasFinal {
+		context.final = true
+		config.apply(context, options)
+	}
+	if hostname, ok := options["hostname"]; ok {
+		options["hostname"] = expandSSHConfigTokens(hostname, alias)
+	}
+	return options
+}
+
+func (config *SSHConfig) apply(context sshConfigContext, options map[string]string) {
+	for _, block := range config.blocks {
+		matched := true
+		for _, condition := range block.Conditions {
+			if !condition.matches(context, options) {
+				matched = false
+				break
+			}
+		}
+		if !matched {
+			continue
+		}
+		for _, directive := range block.Directives {
+			if _, ok := options[directive.Key]; !ok {
+				options[directive.Key] = strings.Join(directive.Args, " ")
+			}
+		}
+	}
+}
+
+func (condition sshConfigCondition) matches(context sshConfigContext, options map[string]string) bool {
+	if !condition.IsMatch {
+		return matchHostPatterns(context.alias, condition.HostPatterns)
+	}
+	for _, criterion := range condition.Criteria {
+		var matched bool
+		switch criterion.Name {
+		case "all":
+			matched = true
+		case "final":
+			matched = context.final
+		case "host":
+			hostname := context.alias
+			if value, ok := options["hostname"]; ok {
+				hostname = expandSSHConfigTokens(value, context.alias)
+			}
+			matched = matchPatternList(hostname, criterion.Arg, true)
+		case "originalhost":
+			matched = matchPatternList(context.alias, criterion.Arg, true)
+		case "user":
+			remoteUser := context.localUser
+			if value, ok := options["user"]; ok {
+				remoteUser = value
+			}
+			matched = matchPatternList(remoteUser, criterion.Arg, false)
+		case "localuser":
+			matched = matchPatternList(context.localUser, criterion.Arg, false)
+		case "tagged":
+			matched = matchPatternList(options["tag"], criterion.Arg, false)
+		default:
+			// canonical/exec/localnetwork 等需要实际连接环境的条件不予匹配
+			matched = false
+		}
+		if criterion.Negate {
+			matched = !matched
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+func parseMatchCriteria(args []string) ([]sshMatchCriterion, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("Match 缺少条件")
+	}
+	var criteria []sshMatchCriterion
+	for i := 0; i < len(args); i++ {
+		criterion := sshMatchCriterion{Name: strings.ToLower(args[i])}
+		if strings.HasPrefix(criterion.Name, "!") {
+			criterion.Negate = true
+			criterion.Name = criterion.Name[1:]
+		}
+		switch criterion.Name {
+		case "all", "canonical", "final":
+		default:
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("Match 条件缺少参数: %s", args[i])
+			}
+			i++
+			criterion.Arg = args[i]
+		}
+		criteria = append(criteria, criterion)
+	}
+	return criteria, nil
+}
+
+// splitSSHConfigLine 拆分为小写的配置项名称与参数, 支持 Key=Value 与双引号参数, 空行与注释返回空名称
+func splitSSHConfigLine(line string) (string, []string, error) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return "", nil, nil
+	}
+	keyEnd := strings.IndexAny(line, " \t=")
+	if keyEnd == -1 {
+		return strings.ToLower(line), nil, nil
+	}
+	key := strings.ToLower(line[:keyEnd])
+	rest := strings.TrimLeft(line[keyEnd:], " \t")
+	rest = strings.TrimLeft(strings.TrimPrefix(rest, "="), " \t")
+	var args []string
+	var builder strings.Builder
+	inQuote, hasArg := false, false
+	for _, char := range rest {
+		switch {
+		case char == '"':
+			inQuote = !inQuote
+			hasArg = true
+		case !inQuote && (char == ' ' || char == '\t'):
+			if hasArg {
+				args = append(args, builder.String())
+				builder.Reset()
+				hasArg = false
+			}
+		default:
+			builder.WriteRune(char)
+			hasArg = true
+		}
+	}
+	if inQuote {
+		return "", nil, fmt.Errorf("引号未闭合")
+	}
+	if hasArg {
+		args = append(args, builder.String())
+	}
+	return key, args, nil
+}
+
+// matchHostPatterns 匹配 Host 行的主机模式, 忽略大小写
+func matchHostPatterns(alias string, patterns []string) bool {
+	return matchPatterns(alias, patterns, true)
+}
+
+// matchPatternList 匹配 Match 条件中逗号分隔的模式列表
+func matchPatternList(value string, list string, foldCase bool) bool {
+	return matchPatterns(value, strings.Split(list, ","), foldCase)
+}
+
+// matchPatterns 任一肯定模式命中且没有否定模式命中时匹配
+func matchPatterns(value string, patterns []string, foldCase bool) bool {
+	if foldCase {
+		value = strings.ToLower(value)
+	}
+	matched := false
+	for _, pattern := range patterns {
+		if foldCase {
+			pattern = strings.ToLower(pattern)
+		}
+		if strings.HasPrefix(pattern, "!") {
+			if matchPattern(value, pattern[1:]) {
+				return false
+			}
+			continue
+		}
+		if matchPattern(value, pattern) {
+			matched = true
+		}
+	}
+	return matched
+}
+
+// matchPattern 仅支持 * 与 ? 通配符, 与 OpenSSH 一致
+func matchPattern(value string, pattern string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			pattern = strings.TrimLeft(pattern, "*")
+			if pattern == "" {
+				return true
+			}
+			for i := 0; i <= len(value); i++ {
+				if matchPattern(value[i:], pattern) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if value == "" {
+				return false
+			}
+		default:
+			if value == "" || value[0] != pattern[0] {
+				return false
+			}
+		}
+		value = value[1:]
+		pattern = pattern[1:]
+	}
+	return value == ""
+}
+
+// expandSSHConfigTokens 展开 HostName 中的 %h 与 %%
+func expandSSHConfigTokens(value string, alias string) string {
+	var builder strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] == '%' && i+1 < len(value) {
+			switch value[i+1] {
+			case 'h':
+				builder.WriteString(alias)
+				i++
+				continue
+			case '%':
+				builder.WriteByte('%')
+				i++
+				continue
+			}
+		}
+		builder.WriteByte(value[i])
+	}
+	return builder.String()
+}
+
+func expandHomeDir(path string) string {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		if homeDir, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(homeDir, path[1:])
+		}
+	}
+	return path
+}
@@ -0,0 +1,247 @@
+package ssh
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// 选择器保存的主机写入该文件, 位于 ~/.ssh/config.d
+const managedSSHConfigName = "trance.conf"
+
+// 可转换为配置项的无参数 ssh 选项
+var sshFlagConfigLines = map[string][2]string{
+	"-4": {"AddressFamily", "inet"},
+	"-6": {"AddressFamily", "inet6"},
+	"-A": {"ForwardAgent", "yes"},
+	"-a": {"ForwardAgent", "no"},
+	"-C": {"Compression", "yes"},
+	"-T": {"RequestTTY", "no"},
+	"-t": {"RequestTTY", "yes"},
+	"-X": {"ForwardX11", "yes"},
+	"-x": {"ForwardX11", "no"},
+	"-q": {"LogLevel", "QUIET"},
+}
+
+// 可转换为配置项的带参数 ssh 选项, -L/-R/-D 单独按端口转发处理
+var sshArgConfigKeys = map[string]string{
+	"-b": "BindAddress",
+	"-c": "Ciphers",
+	"-i": "IdentityFile",
+	"-J": "ProxyJump",
+	"-l": "User",
+	"-m": "MACs",
+	"-p": "Port",
+}
+
+// hostConfigEntry 为待写入的 Host 块, Lines 按写入顺序排列
+type hostConfigEntry struct {
+	Alias string
+	Lines [][2]string
+}
+
+// newHostConfigEntry 将目标主机、ssh 参数与端口转发转换为 Host 块
+// ssh 对同一配置项取首个值, 参数中的 User/Port/ProxyJump 优先于 target 中的值, 与 buildSSHArgs 的顺序一致
+func newHostConfigEntry(alias string, target Host, sshOptions []string, forwards []Forward) (hostConfigEntry, error) {
+	if err := validateHostAlias(alias); err != nil {
+		return hostConfigEntry{}, err
+	}
+	optionLines, err := sshOptionConfigLines(sshOptions)
+	if err != nil {
+		return hostConfigEntry{}, err
+	}
+	for _, forward := range forwards {
+		optionLines = append(optionLines, forward.configLine())
+	}
+	entry := hostConfigEntry{Alias: alias}
+	entry.Lines = append(entry.Lines, [2]string{"HostName", target.Hostname})
+	for _, line := range [][2]string{{"User", target.User}, {"Port", target.Port}, {"ProxyJump", target.ProxyJump}} {
+		if line[1] != "" && !hasConfigKey(optionLines, line[0]) {
+			entry.Lines = append(entry.Lines, line)
+		}
+	}
+	entry.Lines = append(entry.Lines, optionLines...)
+	return entry, nil
+}
+
+func validateHostAlias(alias string) error {
+	if alias == "" {
+		return fmt.Errorf("别名不能为空")
+	}
+	if strings.ContainsAny(alias, " \t\"#*?!,") {
+		return fmt.Errorf("别名不能包含空白、引号、注释或通配符: %s", alias)
+	}
+	return nil
+}
+
+// sshOptionConfigLines 转换 -o Key=Value 与常用的 ssh 选项, 无法转换的选项返回错误
+func sshOptionConfigLines(sshOptions []string) ([][2]string, error) {
+	var lines [][2]string
+	for i := 0; i < len(sshOptions); i++ {
+		arg := sshOptions[i]
+		if line, ok := sshFlagConfigLines[arg]; ok {
+			lines = append(lines, line)
+			continue
+		}
+		if len(arg) < 2 || arg[0] != '-' {
+			return nil, fmt.Errorf("无法转换为配置项的 ssh 参数: %s", arg)
+		}
+		flag := arg[:2]
+		if _, ok := sshArgConfigKeys[flag]; !ok && !strings.Contains("-o -L -R -D", flag) {
+			return nil, fmt.Errorf("无法转换为配置项的 ssh 参数: %s", arg)
+		}
+		// 参数值可以紧跟选项, 如 -p2222 与 -oPort=2222
+		value := arg[2:]
+		if value == "" {
+			if i+1 >= len(sshOptions) {
+				return nil, fmt.Errorf("ssh 参数缺少值: %s", arg)
+			}
+			i++
+			value = sshOptions[i]
+		}
+		switch flag {
+		case "-o":
+			key, optionValue, ok := strings.Cut(strings.TrimSpace(value), "=")
+			if !ok {
+				key, optionValue, ok = strings.Cut(strings.TrimSpace(value), " ")
+			}
+			key, optionValue = strings.TrimSpace(key), strings.TrimSpace(optionValue)
+			if !ok || key == "" || optionValue == "" {
+				return nil, fmt.Errorf("ssh 配置项格式无效: %s", value)
+			}
+			lines = append(lines, [2]string{key, optionValue})
+		case "-L", "-R", "-D":
+			forward, err := parseForward(flag[1:] + ":" + value)
+			if err != nil {
+				return nil, err
+			}
+			lines = append(lines, forward.configLine())
+		default:
+			lines = append(lines, [2]string{sshArgConfigKeys[flag], value})
+		}
+	}
+	return lines, nil
+}
+
+func hasConfigKey(lines [][2]string, key string) bool {
+	for _, line := range lines {
+		if strings.EqualFold(line[0], key) {
+			return true
+		}
+	}
+	return false
+}
+
+// configLine 返回 LocalForward 等配置项, 监听地址与目标以空格分隔
+func (forward Forward) configLine() [2]string {
+	listen := forward.BindPort
+	if forward.BindAddress != "" {
+		listen = bracketIPv6(forward.BindAddress) + ":" + listen
+	}
+	switch forward.Kind {
+	case "L":
+		return [2]string{"LocalForward", listen + " " + bracketIPv6(forward.TargetHost) + ":" + forward.TargetPort}
+	case "R":
+		return [2]string{"RemoteForward", listen + " " + bracketIPv6(forward.TargetHost) + ":" + forward.TargetPort}
+	}
+	return [2]string{"DynamicForward", listen}
+}
+
+// Render 返回 Host 块文本, 含空白的值加引号
+func (entry hostConfigEntry) Render() string {
+	var builder strings.Builder
+	builder.WriteString("Host " + entry.Alias + "\n")
+	for _, line := range entry.Lines {
+		value := line[1]
+		// 端口转发的监听地址与目标为两个参数, 不加引号
+		if strings.ContainsAny(value, " \t") && !strings.HasSuffix(line[0], "Forward") {
+			value = `"` + value + `"`
+		}
+		builder.WriteString("    " + line[0] + " " + value + "\n")
+	}
+	return builder.String()
+}
+
+// findDuplicateHost 返回与新 Host 块别名相同, 或无额外配置项且目标相同的 ssh_config 主机
+func findDuplicateHost(entry hostConfigEntry, hosts []Host) (Host, bool) {
+	target := Host{}
+	extraLines := 0
+	for _, line := range entry.Lines {
+		switch strings.ToLower(line[0]) {
+		case "hostname":
+			target.Hostname = line[1]
+		case "user":
+			target.User = line[1]
+		case "port":
+			target.Port = line[1]
+		case "proxyjump":
+			target.ProxyJump = line[1]
+		default:
+			extraLines++
+		}
+	}
+	for _, host := range hosts {
+		if host.Source != "ssh_config" || host.History != nil {
+			continue
+		}
+		if host.Alias == entry.Alias {
+			return host, true
+		}
+		if extraLines == 0 && strings.EqualFold(host.Hostname, target.Hostname) && cmp.Or(host.Port, "22") == cmp.Or(target.Port, "22") &&
+			host.ProxyJump == target.ProxyJump && (target.User == "" || host.User == target.User) {
+			return host, true
+		}
+	}
+	return Host{}, false
+}
+
+// managedSSHConfigPath 返回 ~/.ssh/config.d/trance.conf
+func managedSSHConfigPath() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("获取用户主目录失败\n%w", err)
+	}
+	return filepath.Join(homeDir, ".ssh", "config.d", managedSSHConfigName), nil
+}
+
+// appendHostConfigEntry 将 Host 块追加到托管文件, 返回文件路径
+func appendHostConfigEntry(entry hostConfigEntry) (string, error) {
+	path, err := managedSSHConfigPath()
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return "", fmt.Errorf("创建配置目录失败: %s\n%w", filepath.Dir(path), err)
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return "", fmt.Errorf("打开 SSH 配置文件失败: %s\n%w", path, err)
+	}
+	defer func(file *os.File) {
+		_ = file.Close()
+	}(file)
+	info, err := file.Stat()
+	if err != nil {
+		return "", fmt.Errorf("打开 SSH 配置文件失败: %s\n%w", path, err)
+	}
+	content := entry.Render()
+	if info.Size() == 0 {
+		content = "# Hosts saved from the trance ssh selector\n\n" + content
+	} else {
+		content = "\n" + content
+	}
+	if _, err := file.WriteString(content); err != nil {
+		return "", fmt.Errorf("写入 SSH 配置文件失败: %s\n%w", path, err)
+	}
+	return path, nil
+}
+
+// managedSSHConfigIncluded 检查 ~/.ssh/config 是否 Include 了托管文件, 否则 ssh 本身无法使用保存的主机
+func managedSSHConfigIncluded(path string) bool {
+	sshDir := filepath.Dir(filepath.Dir(path))
+	sshConfig := NewSSHConfig()
+	_ = sshConfig.ReadFile(filepath.Join(sshDir, "config"), sshDir)
+	return sshConfig.Visited(path)
+}
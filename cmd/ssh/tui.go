@@ -1,13 +1,19 @@
 package ssh
 
 import (
+	"cmp"
 	"fmt"
+	"slices"
 	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/charmbracelet/bubbles/table"
 	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/mattn/go-runewidth"
+	"github.com/sahilm/fuzzy"
 )
 
 var (
@@ -16,6 +22,34 @@ var (
 	tuiHelpStyle             = lipgloss.NewStyle().Foreground(lipgloss.Color("241")).PaddingTop(1)
 	tuiTextInputLabelStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("241")).Width(16) // 调整宽度以对齐
 	tuiTextInputFocusedStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("205"))
+	tuiDetailErrorStyle      = lipgloss.NewStyle().Foreground(lipgloss.Color("196"))
+	tuiMatchStyle            = lipgloss.NewStyle().Foreground(lipgloss.Color("205")).Bold(true)
+	tuiKeyStatusStyle        = lipgloss.NewStyle().Foreground(lipgloss.Color("241"))
+	tuiHostTableStyles       = newHostTableStyles()
+)
+
+func newHostTableStyles() table.Styles {
+	styles := table.DefaultStyles()
+	styles.Header = styles.Header.
+		BorderStyle(lipgloss.NormalBorder()).
+		BorderForeground(lipgloss.Color("240")).
+		BorderBottom(true).
+		Bold(false)
+	styles.Selected = styles.Selected.
+		Foreground(lipgloss.Color("229")).
+		Background(lipgloss.Color("57")).
+		Bold(false)
+	return styles
+}
+
+// 详情面板固定行数, 保持表格高度稳定
+const tuiDetailLines = 8
+
+// 选择命令片段的阶段
+const (
+	snippetModeNone = iota
+	snippetModePick
+	snippetModeParam
 )
 
 type TuiModel struct {
@@ -29,18 +63,61 @@ type TuiModel struct {
 	sshOptionsInput textinput.Model
 	// remoteCommandArgs 输入框
 	remoteCommandArgsInput textinput.Model
+	// 端口转发输入框
+	forwardsInput textinput.Model
+	// 按主机预设的端口转发, 选择主机时填入空的端口转发输入框
+	forwardPresets []forwardPreset
+	// 确认时输入校验失败的提示, 以及保存主机等操作的结果
+	inputErr  string
+	inputInfo string
+	// 保存为 ssh_config 主机时输入别名, saving 时按键只发送给该输入框
+	aliasInput textinput.Model
+	saving     bool
+	// 远程命令片段, snippetMode 非零时按键只用于选择片段或填写参数
+	snippets       []snippet
+	snippetMode    int
+	snippetInput   textinput.Model
+	snippetMatches []snippet
+	snippetCursor  int
+	// 已选择的片段, 当前填写的参数下标与已填写的值
+	snippetChosen snippet
+	snippetParam  int
+	snippetValues map[string]string
 	// 传入的 Host 列表
 	originalHosts []Host
-	// 当前聚焦的输入框索引, 0:hostStrInput, 1:proxyJumpInput, 2:sshOptionsInput, 3:remoteCommandArgsInput
-	focusIndex              int
+	// 用于判断输入的主机是否已知
+	knownHosts *KnownHosts
+	// 过滤后的 Host 列表, 与表格行一一对应
+	filteredHosts []hostMatch
+	// 表格首个可见行, 自绘表格时用于滚动
+	tableOffset int
+	// 当前聚焦的输入框索引, 0:hostStrInput, 1:proxyJumpInput, 2:sshOptionsInput, 3:remoteCommandArgsInput, 4:forwardsInput
+	focusIndex int
+	// 是否显示 ssh -G 详情面板
+	showDetail bool
+	// 按 ssh -G 参数缓存的结果, 会话内有效
+	resolveCache map[string]*resolveResult
+	// 是否在后台探测可见主机的可达性, 以及是否读取 SSH banner
+	probe       bool
+	probeBanner bool
+	// 按 host:port 缓存的探测结果
+	probeCache map[string]*probeResult
+	// 窗口高度, 切换详情面板时用于调整表格高度
+	height int
+	// 内容宽度, 用于截断片段列表
+	width int
+	// 按标记顺序记录的主机, 非空时确认后批量执行
+	markedHosts             []Host
 	quitting                bool
+	resultHosts             []Host
 	resultHostStr           string
 	resultProxyJump         string
 	resultSshOptions        []string
 	resultRemoteCommandArgs []string
+	resultForwards          []Forward
 }
 
-func newTuiModel(hosts []Host, defaultPresetHostStr string, defaultSshOptions []string, defaultRemoteCommandArgs []string) TuiModel {
+func newTuiModel(hosts []Host, options SelectorOptions) TuiModel {
 	// HostStr 输入框
 	hostStrInput := textinput.New()
 	hostStrInput.Placeholder = "user@host:port or alias"
@@ -48,7 +125,7 @@ func newTuiModel(hosts []Host, defaultPresetHostStr string, defaultSshOptions []
 	hostStrInput.CharLimit = 128
 	hostStrInput.PromptStyle = tuiTextInputFocusedStyle
 	hostStrInput.TextStyle = tuiTextInputFocusedStyle
-	hostStrInput.SetValue(defaultPresetHostStr)
+	hostStrInput.SetValue(options.HostStr)
 	// ProxyJump 输入框
 	proxyJumpInput := textinput.New()
 	proxyJumpInput.Placeholder = "user@host:port or alias"
@@ -57,31 +134,34 @@ func newTuiModel(hosts []Host, defaultPresetHostStr string, defaultSshOptions []
 	sshOptionsInput := textinput.New()
 	sshOptionsInput.Placeholder = "-o StrictHostKeyChecking=no -o ConnectTimeout=5"
 	sshOptionsInput.CharLimit = 256
-	sshOptionsInput.SetValue(strings.Join(defaultSshOptions, " "))
+	sshOptionsInput.SetValue(shellJoin(options.SshOptions))
 	// remoteCommandArgs 输入框
 	remoteCommandArgsInput := textinput.New()
 	remoteCommandArgsInput.Placeholder = "ls -l /"
 	remoteCommandArgsInput.CharLimit = 256
-	remoteCommandArgsInput.SetValue(strings.Join(defaultRemoteCommandArgs, " "))
+	// 与 ssh 一样以空格拼接命令行参数, 即远程 shell 实际收到的命令
+	remoteCommandArgsInput.SetValue(strings.Join(options.RemoteCommandArgs, " "))
+	// 端口转发输入框
+	forwardsInput := textinput.New()
+	forwardsInput.Placeholder = "L:5432:db:5432 R:8080:localhost:80 D:1080"
+	forwardsInput.CharLimit = 256
+	forwardsInput.SetValue(strings.Join(options.Forwards, " "))
+	// 保存主机的别名输入框
+	aliasInput := textinput.New()
+	aliasInput.Placeholder = "alias"
+	aliasInput.CharLimit = 64
+	aliasInput.PromptStyle = tuiTextInputFocusedStyle
+	aliasInput.TextStyle = tuiTextInputFocusedStyle
+	// 片段过滤与参数输入框
+	snippetInput := textinput.New()
+	snippetInput.CharLimit = 256
+	snippetInput.PromptStyle = tuiTextInputFocusedStyle
+	snippetInput.TextStyle = tuiTextInputFocusedStyle
 	// HostStr 预览表格
-	hostTableColumns := []table.Column{
-		{Title: "Alias", Width: 15},
-		{Title: "User", Width: 10},
-		{Title: "Hostname", Width: 15},
-		{Title: "Port", Width: 6},
-		{Title: "ProxyJump", Width: 15},
-		{Title: "Source", Width: 15},
-	}
+	hostTableColumns := newHostTableColumns(15)
 	hostTableRows := make([]table.Row, len(hosts))
 	for i, host := range hosts {
-		hostTableRows[i] = table.Row{
-			host.Alias,
-			host.User,
-			host.Hostname,
-			host.Port,
-			host.ProxyJump,
-			host.Source,
-		}
+		hostTableRows[i] = hostRow(host)
 	}
 	hostTable := table.New(
 		table.WithColumns(hostTableColumns),
@@ -89,17 +169,7 @@ func newTuiModel(hosts []Host, defaultPresetHostStr string, defaultSshOptions []
 		table.WithFocused(true),
 		table.WithHeight(3),
 	)
-	hostTableStyle := table.DefaultStyles()
-	hostTableStyle.Header = hostTableStyle.Header.
-		BorderStyle(lipgloss.NormalBorder()).
-		BorderForeground(lipgloss.Color("240")).
-		BorderBottom(true).
-		Bold(false)
-	hostTableStyle.Selected = hostTableStyle.Selected.
-		Foreground(lipgloss.Color("229")).
-		Background(lipgloss.Color("57")).
-		Bold(false)
-	hostTable.SetStyles(hostTableStyle)
+	hostTable.SetStyles(tuiHostTableStyles)
 
 	model := TuiModel{
 		hostTable:              hostTable,
@@ -107,15 +177,26 @@ func newTuiModel(hosts []Host, defaultPresetHostStr string, defaultSshOptions []
 		proxyJumpInput:         proxyJumpInput,
 		sshOptionsInput:        sshOptionsInput,
 		remoteCommandArgsInput: remoteCommandArgsInput,
+		forwardsInput:          forwardsInput,
+		aliasInput:             aliasInput,
+		snippets:               options.Snippets,
+		snippetInput:           snippetInput,
+		forwardPresets:         options.ForwardPresets,
 		originalHosts:          hosts,
 		focusIndex:             0,
+		showDetail:             options.Resolve,
+		knownHosts:             options.KnownHosts,
+		resolveCache:           make(map[string]*resolveResult),
+		probe:                  options.Probe,
+		probeBanner:            options.ProbeBanner,
+		probeCache:             make(map[string]*probeResult),
 	}
 	model.filterHost()
 	return model
 }
 
 func (model *TuiModel) Init() tea.Cmd {
-	return textinput.Blink
+	return tea.Batch(textinput.Blink, model.resolveSelected(), model.probeVisible())
 }
 
 func (model *TuiModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
@@ -123,32 +204,84 @@ func (model *TuiModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	var cmds []tea.Cmd
 	// 快捷键处理
 	switch msg := msg.(type) {
+	case resolveMsg:
+		model.resolveCache[msg.key] = &resolveResult{Config: msg.config, Err: msg.err}
+		return model, nil
+	case probeMsg:
+		model.probeCache[msg.address] = &msg.result
+		return model, nil
 	case tea.KeyMsg:
+		model.inputErr = ""
+		model.inputInfo = ""
+		if model.saving {
+			return model.updateSaving(msg)
+		}
+		if model.snippetMode != snippetModeNone {
+			return model.updateSnippet(msg)
+		}
 		switch msg.String() {
+		// 切换 ssh -G 详情面板
+		case "ctrl+g":
+			model.showDetail = !model.showDetail
+			model.resizeTable()
+			return model, model.resolveSelected()
+		// 将当前输入保存为 ssh_config 主机
+		case "ctrl+s":
+			model.startSaving()
+			return model, nil
+		// 选择命令片段填入远程命令
+		case "ctrl+r":
+			model.startSnippetPicker()
+			return model, nil
 		// 退出应用
 		case "ctrl+c", "esc":
 			model.quitting = true
 			return model, tea.Quit
+		// 标记或取消标记当前主机
+		case "ctrl+t":
+			if host, ok := model.selectedHost(); ok {
+				model.toggleMarked(host)
+			}
+			return model, nil
 		// 确认选择并退出
 		case "enter":
+			forwards, err := parseForwards(strings.Fields(model.forwardsInput.Value()))
+			if err != nil {
+				model.inputErr = err.Error()
+				return model, nil
+			}
+			sshOptions, err := splitShellWords(model.sshOptionsInput.Value())
+			if err != nil {
+				model.inputErr = "SSH Options: " + err.Error()
+				return model, nil
+			}
+			remoteCommandArgs, err := parseRemoteCommand(model.remoteCommandArgsInput.Value())
+			if err != nil {
+				model.inputErr = "Remote Command: " + err.Error()
+				return model, nil
+			}
+			model.resultForwards = forwards
+			model.resultHosts = model.markedHosts
 			model.resultHostStr = model.hostStrInput.Value()
 			model.resultProxyJump = model.proxyJumpInput.Value()
-			model.resultSshOptions = strings.Fields(model.sshOptionsInput.Value())
-			model.resultRemoteCommandArgs = strings.Fields(model.remoteCommandArgsInput.Value())
+			model.resultSshOptions = sshOptions
+			model.resultRemoteCommandArgs = remoteCommandArgs
 			model.quitting = true
 			return model, tea.Quit
 		// 切换输入框焦点
 		case "tab":
-			model.focusIndex = (model.focusIndex + 1) % 4
+			model.focusIndex = (model.focusIndex + 1) % 5
 			// 先将所有输入框设为失焦状态
 			model.hostStrInput.Blur()
 			model.proxyJumpInput.Blur()
 			model.sshOptionsInput.Blur()
 			model.remoteCommandArgsInput.Blur()
+			model.forwardsInput.Blur()
 			model.hostStrInput.PromptStyle, model.hostStrInput.TextStyle = lipgloss.NewStyle(), lipgloss.NewStyle()
 			model.proxyJumpInput.PromptStyle, model.proxyJumpInput.TextStyle = lipgloss.NewStyle(), lipgloss.NewStyle()
 			model.sshOptionsInput.PromptStyle, model.sshOptionsInput.TextStyle = lipgloss.NewStyle(), lipgloss.NewStyle()
 			model.remoteCommandArgsInput.PromptStyle, model.remoteCommandArgsInput.TextStyle = lipgloss.NewStyle(), lipgloss.NewStyle()
+			model.forwardsInput.PromptStyle, model.forwardsInput.TextStyle = lipgloss.NewStyle(), lipgloss.NewStyle()
 			// 根据 focusIndex 设置当前聚焦的输入框
 			switch model.focusIndex {
 			case 0:
@@ -167,23 +300,22 @@ func (model *TuiModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				model.remoteCommandArgsInput.Focus()
 				model.remoteCommandArgsInput.PromptStyle = tuiTextInputFocusedStyle
 				model.remoteCommandArgsInput.TextStyle = tuiTextInputFocusedStyle
+			case 4:
+				model.forwardsInput.Focus()
+				model.forwardsInput.PromptStyle = tuiTextInputFocusedStyle
+				model.forwardsInput.TextStyle = tuiTextInputFocusedStyle
 			}
 			model.filterHost()
 			return model, nil
 		// 空格选择 HostStr
 		case " ":
-			if (model.focusIndex == 0 || model.focusIndex == 1) && len(model.hostTable.Rows()) > 0 && len(model.hostTable.SelectedRow()) > 0 {
-				selectedAlias := model.hostTable.SelectedRow()[0]
-				var selectedHost Host
-				found := false
-				for _, host := range model.originalHosts {
-					if host.Alias == selectedAlias {
-						selectedHost = host
-						found = true
-						break
+			if (model.focusIndex == 0 || model.focusIndex == 1) && len(model.hostTable.Rows()) > 0 {
+				if selectedHost, found := model.selectedHost(); found {
+					// 历史记录还原完整命令行
+					if selectedHost.History != nil {
+						model.fillHistory(*selectedHost.History)
+						return model, nil
 					}
-				}
-				if found {
 					var currentInputValue string
 					if model.focusIndex == 0 {
 						currentInputValue = model.hostStrInput.Value()
@@ -223,11 +355,19 @@ func (model *TuiModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 							if selectedHost.Port != "" {
 								valueToSet = valueToSet + ":" + selectedHost.Port
 							}
+							// inventory 等来源的主机不经 ssh_config 解析, 需同时填充 ProxyJump 输入框
+							if model.focusIndex == 0 && selectedHost.ProxyJump != "" {
+								model.proxyJumpInput.SetValue(selectedHost.ProxyJump)
+							}
 						}
 					}
 					if model.focusIndex == 0 {
 						model.hostStrInput.SetValue(valueToSet)
 						model.hostStrInput.SetCursor(len(valueToSet))
+						// 未手动填写端口转发时使用主机的预设
+						if model.forwardsInput.Value() == "" {
+							model.forwardsInput.SetValue(strings.Join(presetForwards(model.forwardPresets, selectedHost), " "))
+						}
 					} else {
 						model.proxyJumpInput.SetValue(valueToSet)
 						model.proxyJumpInput.SetCursor(len(valueToSet))
@@ -255,6 +395,8 @@ func (model *TuiModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		model.sshOptionsInput, cmd = model.sshOptionsInput.Update(msg)
 	case 3:
 		model.remoteCommandArgsInput, cmd = model.remoteCommandArgsInput.Update(msg)
+	case 4:
+		model.forwardsInput, cmd = model.forwardsInput.Update(msg)
 	}
 	cmds = append(cmds, cmd)
 	// 仅当 HostStr 或 ProxyJump 输入框文本变化时才过滤列表
@@ -274,21 +416,487 @@ func (model *TuiModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		model.proxyJumpInput.Width = inputWidth
 		model.sshOptionsInput.Width = inputWidth
 		model.remoteCommandArgsInput.Width = inputWidth
+		model.forwardsInput.Width = inputWidth
+		model.aliasInput.Width = inputWidth
+		model.snippetInput.Width = inputWidth
 		// 表格尺寸
-		model.hostTable.SetHeight(size.Height - 9)
-		flexColumnWidth := (contentWidth - (10 + 6 + 15) - 10) / 3
-		model.hostTable.SetColumns([]table.Column{
-			{Title: "Alias", Width: flexColumnWidth},
-			{Title: "User", Width: 10},
-			{Title: "Hostname", Width: flexColumnWidth},
-			{Title: "Port", Width: 6},
-			{Title: "ProxyJump", Width: flexColumnWidth},
-			{Title: "Source", Width: 15},
-		})
+		model.height = size.Height
+		model.width = contentWidth
+		model.resizeTable()
+		// 固定宽度列之和与每列左右各 1 的内边距
+		flexColumnWidth := (contentWidth - (10 + 6 + 10 + 12 + 5 + 10) - hostColumnCount*2) / 5
+		model.hostTable.SetColumns(newHostTableColumns(max(flexColumnWidth, 8)))
 	}
+	// 光标移动后按需解析新选中的主机
+	cmds = append(cmds, model.resolveSelected())
+	// 过滤或滚动后探测新出现的主机
+	cmds = append(cmds, model.probeVisible())
 	return model, tea.Batch(cmds...)
 }
 
+// startSaving 显示别名输入框, 以输入的主机名作为默认别名
+func (model *TuiModel) startSaving() {
+	hostStr := strings.TrimSpace(model.hostStrInput.Value())
+	if hostStr == "" {
+		model.inputErr = "Host 为空, 无法保存"
+		return
+	}
+	_, server, _ := parseHostString(hostStr)
+	model.aliasInput.SetValue(server)
+	model.aliasInput.SetCursor(len(server))
+	model.aliasInput.Focus()
+	model.saving = true
+}
+
+func (model *TuiModel) updateSaving(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		model.quitting = true
+		return model, tea.Quit
+	case "esc":
+		model.saving = false
+		model.aliasInput.Blur()
+		return model, nil
+	case "enter":
+		// 保存失败时保留输入框, 以便修改别名
+		if err := model.saveHost(strings.TrimSpace(model.aliasInput.Value())); err != nil {
+			model.inputErr = strings.ReplaceAll(err.Error(), "\n", ": ")
+			return model, nil
+		}
+		model.saving = false
+		model.aliasInput.Blur()
+		return model, nil
+	}
+	var cmd tea.Cmd
+	model.aliasInput, cmd = model.aliasInput.Update(msg)
+	return model, cmd
+}
+
+// saveHost 将当前输入写入 ~/.ssh/config.d/trance.conf, 并加入主机列表
+func (model *TuiModel) saveHost(alias string) error {
+	usr, server, port := parseHostString(strings.TrimSpace(model.hostStrInput.Value()))
+	target := Host{Hostname: server, User: usr, Port: port}
+	// 输入为已有别名时使用其地址, 新的 Host 块不会再次匹配原别名
+	for _, host := range model.originalHosts {
+		if host.Alias == server && host.Source == "ssh_config" && host.History == nil {
+			target = Host{Hostname: host.Hostname, User: cmp.Or(usr, host.User), Port: cmp.Or(port, host.Port), ProxyJump: host.ProxyJump}
+			break
+		}
+	}
+	target.ProxyJump = cmp.Or(strings.TrimSpace(model.proxyJumpInput.Value()), target.ProxyJump)
+	forwards, err := parseForwards(strings.Fields(model.forwardsInput.Value()))
+	if err != nil {
+		return err
+	}
+	sshOptions, err := splitShellWords(model.sshOptionsInput.Value())
+	if err != nil {
+		return fmt.Errorf("SSH Options: %w", err)
+	}
+	entry, err := newHostConfigEntry(alias, target, sshOptions, forwards)
+	if err != nil {
+		return err
+	}
+	if duplicate, ok := findDuplicateHost(entry, model.originalHosts); ok {
+		if duplicate.Alias == alias {
+			return fmt.Errorf("Host %s 已存在", alias)
+		}
+		return fmt.Errorf("与已有的 Host %s 配置相同", duplicate.Alias)
+	}
+	path, err := appendHostConfigEntry(entry)
+	if err != nil {
+		return err
+	}
+	model.originalHosts = append(model.originalHosts, Host{
+		Alias:     alias,
+		Hostname:  target.Hostname,
+		User:      target.User,
+		Port:      target.Port,
+		ProxyJump: target.ProxyJump,
+		Source:    "ssh_config",
+	})
+	model.filterHost()
+	model.inputInfo = fmt.Sprintf("saved Host %s to %s", alias, path)
+	if !managedSSHConfigIncluded(path) {
+		model.inputInfo += ", add 'Include config.d/*' to ~/.ssh/config for plain ssh"
+	}
+	return nil
+}
+
+// startSnippetPicker 列出可用于当前主机的片段
+func (model *TuiModel) startSnippetPicker() {
+	if len(model.snippets) == 0 {
+		path, _ := configFilePath("snippets.toml")
+		model.inputErr = "未配置命令片段: " + path
+		return
+	}
+	model.snippetInput.SetValue("")
+	model.snippetInput.Placeholder = "filter"
+	model.snippetInput.Focus()
+	model.snippetMode = snippetModePick
+	model.filterSnippets()
+	if len(model.snippetMatches) == 0 {
+		model.stopSnippet()
+		model.inputErr = "没有适用于所选主机的命令片段"
+	}
+}
+
+// snippetScopeHosts 返回用于判断片段标签的主机, 有标记时为全部标记的主机, 否则为光标所在主机
+func (model *TuiModel) snippetScopeHosts() []Host {
+	if len(model.markedHosts) > 0 {
+		return model.markedHosts
+	}
+	if host, ok := model.selectedHost(); ok {
+		return []Host{host}
+	}
+	return nil
+}
+
+// filterSnippets 按名称与描述模糊匹配适用的片段
+func (model *TuiModel) filterSnippets() {
+	scopeHosts := model.snippetScopeHosts()
+	var candidates []snippet
+	for _, item := range model.snippets {
+		if item.appliesTo(scopeHosts) {
+			candidates = append(candidates, item)
+		}
+	}
+	model.snippetCursor = 0
+	query := strings.TrimSpace(model.snippetInput.Value())
+	if query == "" {
+		model.snippetMatches = candidates
+		return
+	}
+	sources := make([]string, len(candidates))
+	for i, item := range candidates {
+		sources[i] = item.Name + " " + item.Description
+	}
+	model.snippetMatches = nil
+	for _, match := range fuzzy.Find(query, sources) {
+		model.snippetMatches = append(model.snippetMatches, candidates[match.Index])
+	}
+}
+
+func (model *TuiModel) stopSnippet() {
+	model.snippetMode = snippetModeNone
+	model.snippetInput.Blur()
+}
+
+func (model *TuiModel) updateSnippet(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		model.quitting = true
+		return model, tea.Quit
+	case "esc":
+		model.stopSnippet()
+		return model, nil
+	}
+	if model.snippetMode == snippetModePick {
+		switch msg.String() {
+		case "up", "ctrl+p":
+			model.snippetCursor = max(model.snippetCursor-1, 0)
+			return model, nil
+		case "down", "ctrl+n":
+			model.snippetCursor = max(min(model.snippetCursor+1, len(model.snippetMatches)-1), 0)
+			return model, nil
+		case "enter":
+			if len(model.snippetMatches) == 0 {
+				return model, nil
+			}
+			model.snippetChosen = model.snippetMatches[model.snippetCursor]
+			model.snippetValues = make(map[string]string)
+			model.snippetParam = 0
+			model.nextSnippetParam()
+			return model, nil
+		}
+		oldValue := model.snippetInput.Value()
+		var cmd tea.Cmd
+		model.snippetInput, cmd = model.snippetInput.Update(msg)
+		if model.snippetInput.Value() != oldValue {
+			model.filterSnippets()
+		}
+		return model, cmd
+	}
+	param := model.snippetChosen.Params[model.snippetParam]
+	switch msg.String() {
+	// 在可选值之间切换
+	case "tab":
+		if len(param.Options) > 0 {
+			index := (slices.Index(param.Options, model.snippetInput.Value()) + 1) % len(param.Options)
+			model.snippetInput.SetValue(param.Options[index])
+			model.snippetInput.CursorEnd()
+		}
+		return model, nil
+	case "enter":
+		value := model.snippetInput.Value()
+		if len(param.Options) > 0 && !slices.Contains(param.Options, value) {
+			model.inputErr = "可选值: " + strings.Join(param.Options, ", ")
+			return model, nil
+		}
+		if value == "" && !param.Optional {
+			model.inputErr = param.Label + " 不能为空"
+			return model, nil
+		}
+		model.snippetValues[param.Key] = value
+		model.snippetParam++
+		model.nextSnippetParam()
+		return model, nil
+	}
+	var cmd tea.Cmd
+	model.snippetInput, cmd = model.snippetInput.Update(msg)
+	return model, cmd
+}
+
+// nextSnippetParam 显示下一个参数的输入框, 参数均已填写时将命令填入远程命令输入框
+func (model *TuiModel) nextSnippetParam() {
+	if model.snippetParam < len(model.snippetChosen.Params) {
+		param := model.snippetChosen.Params[model.snippetParam]
+		model.snippetMode = snippetModeParam
+		model.snippetInput.Placeholder = param.Label
+		model.snippetInput.SetValue(param.Default)
+		model.snippetInput.CursorEnd()
+		return
+	}
+	command := model.snippetChosen.render(model.snippetValues)
+	// 超出长度的部分会被输入框截断
+	if limit := model.remoteCommandArgsInput.CharLimit; utf8.RuneCountInString(command) > limit {
+		model.inputErr = fmt.Sprintf("片段 %s 生成的命令超过 %d 个字符", model.snippetChosen.Name, limit)
+		model.stopSnippet()
+		return
+	}
+	model.remoteCommandArgsInput.SetValue(command)
+	model.remoteCommandArgsInput.CursorEnd()
+	model.inputInfo = "snippet: " + model.snippetChosen.Name
+	model.stopSnippet()
+}
+
+func (model *TuiModel) renderSnippetTitle() string {
+	if model.snippetMode == snippetModePick {
+		return lipgloss.JoinHorizontal(lipgloss.Left, tuiTitleStyle.Render("Select a Snippet: "), model.snippetInput.View())
+	}
+	param := model.snippetChosen.Params[model.snippetParam]
+	title := lipgloss.JoinHorizontal(lipgloss.Left, tuiTitleStyle.Render(fmt.Sprintf("%s - %s: ", model.snippetChosen.Name, param.Label)), model.snippetInput.View())
+	if len(param.Options) > 0 {
+		title += "  " + tuiKeyStatusStyle.Render("tab: "+strings.Join(param.Options, " | "))
+	}
+	return title
+}
+
+// renderSnippets 在表格位置绘制片段列表或命令预览, 行数与表格一致以保持布局稳定
+func (model *TuiModel) renderSnippets() string {
+	height := model.hostTable.Height()
+	truncate := func(line string) string {
+		if model.width <= 0 {
+			return line
+		}
+		return runewidth.Truncate(line, model.width, "…")
+	}
+	var lines []string
+	if model.snippetMode == snippetModePick {
+		nameWidth := 0
+		for _, item := range model.snippetMatches {
+			nameWidth = max(nameWidth, runewidth.StringWidth(item.Name))
+		}
+		lines = append(lines, tuiHostTableStyles.Header.Render(truncate(fmt.Sprintf("%d snippets", len(model.snippetMatches)))))
+		start := max(0, model.snippetCursor-height+1)
+		for i := start; i < len(model.snippetMatches) && i < start+height; i++ {
+			item := model.snippetMatches[i]
+			line := truncate(fmt.Sprintf(" %s  %s  %s", runewidth.FillRight(item.Name, nameWidth), item.Description, strings.Join(strings.Fields(item.Command), " ")))
+			if i == model.snippetCursor {
+				line = tuiHostTableStyles.Selected.Render(runewidth.FillRight(line, model.width))
+			}
+			lines = append(lines, line)
+		}
+	} else {
+		// 预览中未填写的参数使用当前输入或默认值
+		values := make(map[string]string)
+		for i, param := range model.snippetChosen.Params {
+			switch {
+			case i < model.snippetParam:
+				values[param.Key] = model.snippetValues[param.Key]
+			case i == model.snippetParam:
+				values[param.Key] = model.snippetInput.Value()
+			default:
+				values[param.Key] = param.Default
+			}
+		}
+		lines = append(lines, tuiHostTableStyles.Header.Render(truncate(model.snippetChosen.Description)))
+		lines = append(lines, lipgloss.JoinHorizontal(lipgloss.Left, tuiTextInputLabelStyle.Render("Command:"), truncate(model.snippetChosen.render(values))))
+	}
+	for len(lines) < height+1 {
+		lines = append(lines, "")
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (model *TuiModel) resizeTable() {
+	if model.height == 0 {
+		return
+	}
+	tableHeight := model.height - 10
+	if model.showDetail {
+		// 标题 + 固定行数 + 空行
+		tableHeight -= tuiDetailLines + 2
+	}
+	model.hostTable.SetHeight(max(tableHeight, 1))
+}
+
+func (model *TuiModel) selectedHost() (Host, bool) {
+	cursor := model.hostTable.Cursor()
+	if cursor < 0 || cursor >= len(model.filteredHosts) {
+		return Host{}, false
+	}
+	return model.filteredHosts[cursor].Host, true
+}
+
+func (model *TuiModel) toggleMarked(host Host) {
+	index := slices.IndexFunc(model.markedHosts, func(marked Host) bool { return marked.Label() == host.Label() })
+	if index == -1 {
+		model.markedHosts = append(model.markedHosts, host)
+	} else {
+		model.markedHosts = slices.Delete(model.markedHosts, index, index+1)
+	}
+}
+
+func (model *TuiModel) isMarked(host Host) bool {
+	return slices.ContainsFunc(model.markedHosts, func(marked Host) bool { return marked.Label() == host.Label() })
+}
+
+// fillHistory 将历史记录填入全部输入框
+func (model *TuiModel) fillHistory(entry HistoryEntry) {
+	model.hostStrInput.SetValue(entry.HostStr)
+	model.hostStrInput.SetCursor(len(entry.HostStr))
+	model.proxyJumpInput.SetValue(entry.ProxyJump)
+	model.sshOptionsInput.SetValue(shellJoin(entry.SshOptions))
+	model.remoteCommandArgsInput.SetValue(strings.Join(entry.RemoteCommandArgs, " "))
+	model.forwardsInput.SetValue(strings.Join(entry.Forwards, " "))
+	model.filterHost()
+}
+
+// resolveSelected 详情面板开启且选中主机未解析时, 返回执行 ssh -G 的命令
+func (model *TuiModel) resolveSelected() tea.Cmd {
+	if !model.showDetail {
+		return nil
+	}
+	host, ok := model.selectedHost()
+	if !ok {
+		return nil
+	}
+	key := resolveKey(host)
+	if _, ok := model.resolveCache[key]; ok {
+		return nil
+	}
+	model.resolveCache[key] = &resolveResult{Loading: true}
+	return resolveHostCmd(host)
+}
+
+// probeVisible 返回探测当前可见且无有效结果的主机的命令, 跳板机后的主机不探测
+func (model *TuiModel) probeVisible() tea.Cmd {
+	if !model.probe {
+		return nil
+	}
+	var cmds []tea.Cmd
+	start, end := model.visibleRange()
+	for _, match := range model.filteredHosts[start:end] {
+		address, ok := probeAddress(match.Host)
+		if !ok {
+			continue
+		}
+		if result, ok := model.probeCache[address]; ok && (result.Pending || time.Since(result.Time) < probeTTL) {
+			continue
+		}
+		model.probeCache[address] = &probeResult{Pending: true}
+		cmds = append(cmds, probeHostCmd(address, model.probeBanner))
+	}
+	return tea.Batch(cmds...)
+}
+
+// probeStatus 返回主机的探测状态, detail 为 true 时返回详情面板使用的完整描述
+func (model *TuiModel) probeStatus(host Host, detail bool) string {
+	if !model.probe {
+		return ""
+	}
+	if host.ProxyJump != "" {
+		if detail {
+			return "via jump " + host.ProxyJump
+		}
+		return "via jump"
+	}
+	address, ok := probeAddress(host)
+	if !ok {
+		return ""
+	}
+	result, ok := model.probeCache[address]
+	if !ok {
+		return ""
+	}
+	if detail {
+		return result.Detail()
+	}
+	return result.Summary()
+}
+
+func (model *TuiModel) renderDetail() string {
+	var lines []string
+	host, ok := model.selectedHost()
+	title := "Effective Config (ssh -G)"
+	if ok {
+		title += ": " + host.Alias
+	}
+	result := model.resolveCache[resolveKey(host)]
+	// 主机清单中的备注不依赖 ssh -G 的结果
+	if ok && host.Note != "" {
+		lines = append(lines, lipgloss.JoinHorizontal(lipgloss.Left, tuiTextInputLabelStyle.Render("Note:"), strings.Join(strings.Fields(host.Note), " ")))
+	}
+	if status := model.probeStatus(host, true); ok && status != "" {
+		lines = append(lines, lipgloss.JoinHorizontal(lipgloss.Left, tuiTextInputLabelStyle.Render("Reachability:"), status))
+	}
+	switch {
+	case !ok:
+		lines = append(lines, "No host selected")
+	case result == nil || result.Loading:
+		lines = append(lines, "Resolving...")
+	case result.Err != nil:
+		lines = append(lines, tuiDetailErrorStyle.Render(strings.SplitN(result.Err.Error(), "\n", 2)[0]))
+	default:
+		config := result.Config
+		target := config.Get("hostname")
+		if strings.Contains(target, ":") {
+			target = "[" + target + "]"
+		}
+		if user := config.Get("user"); user != "" {
+			target = user + "@" + target
+		}
+		if port := config.Get("port"); port != "" {
+			target += ":" + port
+		}
+		var forwards []string
+		for _, forward := range config["localforward"] {
+			forwards = append(forwards, "L "+forward)
+		}
+		for _, forward := range config["remoteforward"] {
+			forwards = append(forwards, "R "+forward)
+		}
+		for _, forward := range config["dynamicforward"] {
+			forwards = append(forwards, "D "+forward)
+		}
+		detailRows := [][2]string{
+			{"Target:", target},
+			{"IdentityFile:", strings.Join(config["identityfile"], ", ")},
+			{"ProxyJump:", config.Get("proxyjump")},
+			{"ProxyCommand:", config.Get("proxycommand")},
+			{"Forwarding:", fmt.Sprintf("agent=%s x11=%s", config.Get("forwardagent"), config.Get("forwardx11"))},
+			{"Forwards:", strings.Join(forwards, ", ")},
+		}
+		for _, row := range detailRows {
+			lines = append(lines, lipgloss.JoinHorizontal(lipgloss.Left, tuiTextInputLabelStyle.Render(row[0]), row[1]))
+		}
+	}
+	for len(lines) < tuiDetailLines {
+		lines = append(lines, "")
+	}
+	return tuiTitleStyle.Render(title) + "\n" + strings.Join(lines, "\n")
+}
+
 func (model *TuiModel) filterHost() {
 	var filterValue string
 	// 只根据 HostStr 或 ProxyJump 输入框的值进行过滤
@@ -301,27 +909,168 @@ func (model *TuiModel) filterHost() {
 		// 当焦点在其他输入框时, 不进行过滤, 显示所有主机
 		filterValue = ""
 	}
-	// 根据 Alias 忽略大小写过滤
-	filterValue = strings.ToLower(filterValue)
-	var hostTableRows []table.Row
-	for _, host := range model.originalHosts {
-		if strings.Contains(strings.ToLower(host.Alias), filterValue) {
-			hostTableRows = append(hostTableRows, table.Row{
-				host.Alias,
-				host.User,
-				host.Hostname,
-				host.Port,
-				host.ProxyJump,
-				host.Source,
-			})
-		}
+	// 在各列中模糊匹配, 按得分排序
+	model.filteredHosts = matchHosts(model.originalHosts, filterValue)
+	hostTableRows := make([]table.Row, len(model.filteredHosts))
+	for i, match := range model.filteredHosts {
+		hostTableRows[i] = hostRow(match.Host)
 	}
 	model.hostTable.SetRows(hostTableRows)
+	model.tableOffset = 0
 	if len(model.hostTable.Rows()) > 0 {
 		model.hostTable.SetCursor(0)
 	}
 }
 
+// renderHostTable 代替 table.Model.View 绘制表格, 以便高亮匹配字符
+// table.Model 按字符截断单元格, 无法处理其中的 ANSI 样式, 仍由其负责光标与按键
+func (model *TuiModel) renderHostTable() string {
+	columns := model.hostTable.Columns()
+	headers := make([]string, 0, len(columns))
+	for _, column := range columns {
+		style := lipgloss.NewStyle().Width(column.Width).MaxWidth(column.Width).Inline(true)
+		headers = append(headers, tuiHostTableStyles.Header.Render(style.Render(runewidth.Truncate(column.Title, column.Width, "…"))))
+	}
+	lines := []string{lipgloss.JoinHorizontal(lipgloss.Top, headers...)}
+
+	height := model.hostTable.Height()
+	cursor := model.hostTable.Cursor()
+	start, end := model.visibleRange()
+	for i := start; i < end; i++ {
+		match := model.filteredHosts[i]
+		baseStyle, matchStyle := lipgloss.NewStyle(), tuiMatchStyle
+		if i == cursor {
+			baseStyle = tuiHostTableStyles.Selected
+			matchStyle = tuiHostTableStyles.Selected.Underline(true).Bold(true)
+		}
+		row := hostRow(match.Host)
+		row[hostColumnProbe] = model.probeStatus(match.Host, false)
+		var builder strings.Builder
+		for column, value := range row {
+			width := columns[column].Width
+			if width <= 0 {
+				continue
+			}
+			value = runewidth.Truncate(value, width, "…")
+			// 与 table.DefaultStyles 的单元格左右内边距一致, 已标记的主机在首列内边距处显示标记
+			if column == hostColumnAlias && model.isMarked(match.Host) {
+				builder.WriteString(matchStyle.Render("*"))
+			} else {
+				builder.WriteString(baseStyle.Render(" "))
+			}
+			builder.WriteString(highlightMatches(value, match.Highlights[column], baseStyle, matchStyle))
+			builder.WriteString(baseStyle.Render(strings.Repeat(" ", width-runewidth.StringWidth(value)+1)))
+		}
+		lines = append(lines, builder.String())
+	}
+	for len(lines) < height+1 {
+		lines = append(lines, "")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// visibleRange 按光标位置调整滚动偏移, 返回可见行的下标范围
+func (model *TuiModel) visibleRange() (int, int) {
+	height := model.hostTable.Height()
+	cursor := model.hostTable.Cursor()
+	if cursor < model.tableOffset {
+		model.tableOffset = cursor
+	} else if cursor >= model.tableOffset+height {
+		model.tableOffset = cursor - height + 1
+	}
+	model.tableOffset = max(0, min(model.tableOffset, len(model.filteredHosts)-height))
+	return model.tableOffset, min(len(model.filteredHosts), model.tableOffset+height)
+}
+
+// highlightMatches 以 matchStyle 渲染 indexes 中的字节下标对应的字符, 连续的同类字符合并渲染
+func highlightMatches(value string, indexes []int, baseStyle lipgloss.Style, matchStyle lipgloss.Style) string {
+	if value == "" {
+		return ""
+	}
+	var builder strings.Builder
+	var segment strings.Builder
+	segmentMatched := false
+	flush := func() {
+		if segment.Len() == 0 {
+			return
+		}
+		if segmentMatched {
+			builder.WriteString(matchStyle.Render(segment.String()))
+		} else {
+			builder.WriteString(baseStyle.Render(segment.String()))
+		}
+		segment.Reset()
+	}
+	for index, char := range value {
+		matched := slices.Contains(indexes, index)
+		if matched != segmentMatched {
+			flush()
+			segmentMatched = matched
+		}
+		segment.WriteRune(char)
+	}
+	flush()
+	return builder.String()
+}
+
+// newHostTableColumns 按 hostColumn 顺序返回表格列, Alias/Hostname/ProxyJump/Tags/Fingerprint 使用可变宽度
+func newHostTableColumns(flexColumnWidth int) []table.Column {
+	return []table.Column{
+		{Title: "Alias", Width: flexColumnWidth},
+		{Title: "User", Width: 10},
+		{Title: "Hostname", Width: flexColumnWidth},
+		{Title: "Port", Width: 6},
+		{Title: "Reach", Width: 10},
+		{Title: "ProxyJump", Width: flexColumnWidth},
+		{Title: "Source", Width: 12},
+		{Title: "Tags", Width: flexColumnWidth},
+		{Title: "Known", Width: 5},
+		{Title: "Key", Width: 10},
+		{Title: "Fingerprint", Width: flexColumnWidth},
+	}
+}
+
+func hostRow(host Host) table.Row {
+	known := ""
+	if host.Known {
+		known = "yes"
+	}
+	return table.Row{
+		host.Label(),
+		host.User,
+		host.Hostname,
+		host.Port,
+		"",
+		host.ProxyJump,
+		host.Source,
+		strings.Join(host.Tags, ","),
+		known,
+		strings.TrimPrefix(host.KeyType, "ssh-"),
+		host.Fingerprint,
+	}
+}
+
+// typedHostKeyStatus 返回 Host 输入框中主机在 known_hosts 中的状态, 输入为别名时使用对应主机的地址
+func (model *TuiModel) typedHostKeyStatus() string {
+	value := strings.TrimSpace(model.hostStrInput.Value())
+	if value == "" || model.knownHosts == nil {
+		return ""
+	}
+	_, server, port := parseHostString(value)
+	for _, host := range model.originalHosts {
+		if host.Alias == server && host.History == nil {
+			server = host.Hostname
+			port = cmp.Or(port, host.Port)
+			break
+		}
+	}
+	keyType, fingerprint, ok := model.knownHosts.Lookup(server, port)
+	if !ok {
+		return "host key: unknown"
+	}
+	return fmt.Sprintf("host key: %s %s", strings.TrimPrefix(keyType, "ssh-"), fingerprint)
+}
+
 func (model *TuiModel) View() string {
 	if model.quitting {
 		return ""
@@ -335,24 +1084,74 @@ func (model *TuiModel) View() string {
 	builder.WriteString(lipgloss.JoinHorizontal(lipgloss.Left, tuiTextInputLabelStyle.Render("SSH Options:"), model.sshOptionsInput.View()))
 	builder.WriteString("\n")
 	builder.WriteString(lipgloss.JoinHorizontal(lipgloss.Left, tuiTextInputLabelStyle.Render("Remote Command:"), model.remoteCommandArgsInput.View()))
+	builder.WriteString("\n")
+	builder.WriteString(lipgloss.JoinHorizontal(lipgloss.Left, tuiTextInputLabelStyle.Render("Forwards:"), model.forwardsInput.View()))
 	builder.WriteString("\n\n")
-	builder.WriteString(tuiTitleStyle.Render("Select an SSH Host"))
+	if model.saving {
+		builder.WriteString(lipgloss.JoinHorizontal(lipgloss.Left, tuiTitleStyle.Render("Save as Host: "), model.aliasInput.View()))
+	} else if model.snippetMode != snippetModeNone {
+		builder.WriteString(model.renderSnippetTitle())
+	} else if len(model.markedHosts) > 0 {
+		builder.WriteString(tuiTitleStyle.Render(fmt.Sprintf("Select SSH Hosts (%d marked)", len(model.markedHosts))))
+	} else {
+		builder.WriteString(tuiTitleStyle.Render("Select an SSH Host"))
+	}
+	if model.inputErr != "" {
+		builder.WriteString("  ")
+		builder.WriteString(tuiDetailErrorStyle.Render(model.inputErr))
+	} else if model.inputInfo != "" {
+		builder.WriteString("  ")
+		builder.WriteString(tuiKeyStatusStyle.Render(model.inputInfo))
+	} else if status := model.typedHostKeyStatus(); status != "" {
+		builder.WriteString("  ")
+		builder.WriteString(tuiKeyStatusStyle.Render(status))
+	}
 	builder.WriteString("\n")
-	builder.WriteString(model.hostTable.View())
-	builder.WriteString(tuiHelpStyle.Render("up/down: navigate | space: select | tab: switch input | enter: connect | esc: quit"))
+	if model.snippetMode != snippetModeNone {
+		builder.WriteString(model.renderSnippets())
+	} else {
+		builder.WriteString(model.renderHostTable())
+	}
+	if model.showDetail {
+		builder.WriteString("\n\n")
+		builder.WriteString(model.renderDetail())
+	}
+	builder.WriteString(tuiHelpStyle.Render("up/down: navigate | space: select | ctrl+t: mark | tab: switch input | ctrl+g: ssh -G details | ctrl+s: save host | ctrl+r: snippets | enter: connect | esc: quit"))
 
 	return tuiAppStyle.Render(builder.String())
 }
 
 type TuiResult struct {
+	// 标记的多个主机, 非空时忽略 HostStr
+	Hosts             []Host
 	HostStr           string
 	ProxyJump         string
 	SshOptions        []string
 	RemoteCommandArgs []string
+	Forwards          []Forward
+}
+
+// SelectorOptions 为选择器的初始状态
+type SelectorOptions struct {
+	HostStr           string
+	SshOptions        []string
+	RemoteCommandArgs []string
+	// 启动时即显示 ssh -G 详情面板
+	Resolve bool
+	// 用于标记输入的主机是否已知, 可为 nil
+	KnownHosts *KnownHosts
+	// 端口转发输入框的初始值, 以及选择主机时使用的预设
+	Forwards       []string
+	ForwardPresets []forwardPreset
+	// 在后台探测可见主机的 TCP 可达性, ProbeBanner 时同时读取 SSH banner
+	Probe       bool
+	ProbeBanner bool
+	// 可填入远程命令的片段
+	Snippets []snippet
 }
 
-func RunSelector(hosts []Host, defaultHostStr string, defaultSshOptions []string, defaultRemoteCommandArgs []string) (TuiResult, error) {
-	model := newTuiModel(hosts, defaultHostStr, defaultSshOptions, defaultRemoteCommandArgs)
+func RunSelector(hosts []Host, options SelectorOptions) (TuiResult, error) {
+	model := newTuiModel(hosts, options)
 	teaProgram := tea.NewProgram(&model, tea.WithAltScreen())
 	teaModel, err := teaProgram.Run()
 	if err != nil {
@@ -360,14 +1159,16 @@ func RunSelector(hosts []Host, defaultHostStr string, defaultSshOptions []string
 	}
 
 	result := teaModel.(*TuiModel)
-	if result.resultHostStr == "" {
+	if result.resultHostStr == "" && len(result.resultHosts) == 0 {
 		return TuiResult{}, fmt.Errorf("未选择主机")
 	}
 
 	return TuiResult{
+		Hosts:             result.resultHosts,
 		HostStr:           result.resultHostStr,
 		ProxyJump:         result.resultProxyJump,
 		SshOptions:        result.resultSshOptions,
 		RemoteCommandArgs: result.resultRemoteCommandArgs,
+		Forwards:          result.resultForwards,
 	}, nil
 }
@@ -0,0 +1,669 @@
+package ssh
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+	"trance-cli/internal/logging"
+
+	"github.com/gookit/color"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// 启动后等待本地端口开始监听的时间, 超时不视为失败
+	tunnelUpWaitTimeout = 5 * time.Second
+	// 停止时等待后台进程退出的时间
+	tunnelDownWaitTimeout = 5 * time.Second
+	// status 显示的日志行数
+	tunnelStatusLogLines = 5
+	// 后台进程获取隧道锁的等待时间
+	tunnelLockTimeout = time.Second
+)
+
+var tunnelExecutor = &TunnelExecutor{}
+
+var tunnelCmd = &cobra.Command{
+	Use:   "tunnel",
+	Short: "Manage named SSH tunnels supervised in the background",
+	Long:  "Tunnel definitions are stored in ~/.config/trance/ssh/tunnels.toml, PID, state and log files in ~/.local/state/trance/ssh/tunnels.",
+}
+
+var tunnelUpCmd = &cobra.Command{
+	Use:   "up [name ...]",
+	Short: "Start tunnels in the background, restarting them with backoff when the connection drops",
+	Run: func(cmd *cobra.Command, args []string) {
+		tunnelExecutor.Up(cmd, args)
+	},
+	ValidArgsFunction: completeTunnelNames,
+}
+
+var tunnelDownCmd = &cobra.Command{
+	Use:   "down [name ...]",
+	Short: "Stop running tunnels",
+	Run: func(cmd *cobra.Command, args []string) {
+		tunnelExecutor.Down(cmd, args)
+	},
+	ValidArgsFunction: completeTunnelNames,
+}
+
+var tunnelListCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"ls"},
+	Short:   "List tunnels with their uptime and listening ports",
+	Args:    cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		tunnelExecutor.List(cmd)
+	},
+}
+
+var tunnelStatusCmd = &cobra.Command{
+	Use:   "status [name ...]",
+	Short: "Show the definition, state and recent log of tunnels",
+	Run: func(cmd *cobra.Command, args []string) {
+		tunnelExecutor.Status(cmd, args)
+	},
+	ValidArgsFunction: completeTunnelNames,
+}
+
+var tunnelAddCmd = &cobra.Command{
+	Use:   "add <name> <host> --forward <spec> [--forward <spec> ...] [-- ssh-options]",
+	Short: "Add or replace a tunnel definition",
+	Args:  cobra.MinimumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		tunnelExecutor.Add(cmd, args)
+	},
+}
+
+var tunnelRmCmd = &cobra.Command{
+	Use:     "rm <name> [name ...]",
+	Aliases: []string{"remove"},
+	Short:   "Stop and remove tunnel definitions",
+	Args:    cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		tunnelExecutor.Remove(cmd, args)
+	},
+	ValidArgsFunction: completeTunnelNames,
+}
+
+// tunnelSuperviseCmd 为 up 启动的后台进程
+var tunnelSuperviseCmd = &cobra.Command{
+	Use:    "supervise <name>",
+	Args:   cobra.ExactArgs(1),
+	Hidden: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		tunnelExecutor.Supervise(cmd, args[0])
+	},
+}
+
+func registerTunnel(parentCmd *cobra.Command) {
+	tunnelUpCmd.Flags().BoolVarP(&tunnelExecutor.All, "all", "a", false, "start all tunnels")
+	tunnelDownCmd.Flags().BoolVarP(&tunnelExecutor.All, "all", "a", false, "stop all running tunnels")
+	tunnelAddCmd.Flags().StringArrayVarP(&tunnelExecutor.Forwards, "forward", "f", nil, "port forward as <L|R|D>:[bind_address:]port[:host:hostport] (repeatable)")
+	tunnelAddCmd.Flags().StringVarP(&tunnelExecutor.ProxyJump, "proxy-jump", "J", "", "jump host as user@host:port or alias")
+	tunnelAddCmd.Flags().BoolVar(&tunnelExecutor.Force, "force", false, "replace an existing tunnel with the same name")
+	_ = tunnelAddCmd.MarkFlagRequired("forward")
+	tunnelCmd.AddCommand(tunnelUpCmd, tunnelDownCmd, tunnelListCmd, tunnelStatusCmd, tunnelAddCmd, tunnelRmCmd, tunnelSuperviseCmd)
+	parentCmd.AddCommand(tunnelCmd)
+}
+
+func completeTunnelNames(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	definitions, _ := loadTunnels()
+	var names []string
+	for _, definition := range definitions {
+		if !slices.Contains(args, definition.Name) {
+			names = append(names, definition.Name)
+		}
+	}
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
+
+type TunnelExecutor struct {
+	logger    logging.Logger
+	All       bool
+	Forwards  []string
+	ProxyJump string
+	Force     bool
+}
+
+func (executor *TunnelExecutor) initLogger(cmd *cobra.Command) {
+	executor.logger = logging.Logger{
+		OutWriter: cmd.OutOrStdout(),
+		ErrWriter: cmd.ErrOrStderr(),
+		State:     logging.LoggerStateNewLine,
+	}
+}
+
+// selectTunnels 按名称选择隧道, all 为 true 或未指定名称时返回全部隧道, 存在未知名称时 ok 为 false
+func (executor *TunnelExecutor) selectTunnels(names []string, all bool) (selected []TunnelDefinition, ok bool) {
+	definitions, err := loadTunnels()
+	if err != nil {
+		executor.logger.PrintfErr(logging.LogModeAppend, true, "%s", err.Error())
+		os.Exit(1)
+	}
+	if all || len(names) == 0 {
+		return definitions, true
+	}
+	ok = true
+	for _, name := range names {
+		definition, found := findTunnel(definitions, name)
+		if !found {
+			executor.logError(name, "隧道不存在")
+			ok = false
+			continue
+		}
+		selected = append(selected, definition)
+	}
+	return selected, ok
+}
+
+func (executor *TunnelExecutor) Up(cmd *cobra.Command, names []string) {
+	executor.initLogger(cmd)
+	if len(names) == 0 && !executor.All {
+		executor.logger.PrintfErr(logging.LogModeAppend, true, "请指定隧道名称或使用 --all")
+		os.Exit(1)
+	}
+	definitions, ok := executor.selectTunnels(names, executor.All)
+	for _, definition := range definitions {
+		if pid, running := runningTunnelPid(definition.Name); running {
+			executor.logSkip(definition.Name, fmt.Sprintf("已在运行 (pid %d)", pid))
+			continue
+		}
+		pid, exited, err := startTunnelSupervisor(definition.Name)
+		if err != nil {
+			executor.logError(definition.Name, err.Error())
+			ok = false
+			continue
+		}
+		executor.logInProgress(definition.Name, fmt.Sprintf("已启动 (pid %d), 等待端口监听", pid))
+		executor.waitListening(definition, pid, exited)
+	}
+	if !ok {
+		os.Exit(1)
+	}
+}
+
+// startTunnelSupervisor 以新会话启动 supervise 子命令, 输出写入隧道日志, exited 在后台进程提前退出时关闭
+func startTunnelSupervisor(name string) (pid int, exited <-chan struct{}, err error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return 0, nil, fmt.Errorf("获取当前程序路径失败\n%w", err)
+	}
+	logPath, err := tunnelFilePath(name, ".log")
+	if err != nil {
+		return 0, nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(logPath), 0o700); err != nil {
+		return 0, nil, fmt.Errorf("创建隧道状态目录失败\n%w", err)
+	}
+	logFile, err := os.OpenFile(logPath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return 0, nil, fmt.Errorf("打开隧道日志失败: %s\n%w", logPath, err)
+	}
+	defer func(file *os.File) {
+		_ = file.Close()
+	}(logFile)
+	// 命令路径去掉根命令名称, 如 ssh tunnel supervise
+	args := append(strings.Fields(tunnelSuperviseCmd.CommandPath())[1:], name)
+	cmd := exec.Command(executable, args...)
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	cmd.SysProcAttr = detachedProcAttr()
+	if err := cmd.Start(); err != nil {
+		return 0, nil, fmt.Errorf("启动后台进程失败\n%w", err)
+	}
+	pid = cmd.Process.Pid
+	// 回收提前退出的后台进程, 当前进程退出后由 init 接管
+	done := make(chan struct{})
+	go func() {
+		_ = cmd.Wait()
+		close(done)
+	}()
+	// 等待后台进程获取隧道锁并写入 PID 文件
+	deadline := time.Now().Add(tunnelUpWaitTimeout)
+	for {
+		if runningPid, running := runningTunnelPid(name); running && runningPid == pid {
+			return pid, done, nil
+		}
+		select {
+		case <-done:
+			return 0, nil, fmt.Errorf("后台进程已退出, 查看日志: %s", logPath)
+		case <-time.After(50 * time.Millisecond):
+		}
+		if time.Now().After(deadline) {
+			_ = cmd.Process.Kill()
+			return 0, nil, fmt.Errorf("后台进程未能获取隧道锁, 查看日志: %s", logPath)
+		}
+	}
+}
+
+// waitListening 等待本地转发端口开始监听, 后台进程提前退出时提示查看日志
+func (executor *TunnelExecutor) waitListening(definition TunnelDefinition, pid int, exited <-chan struct{}) {
+	forwards, _ := parseForwards(definition.Forwards)
+	var addresses []string
+	for _, forward := range forwards {
+		if address, ok := forward.listenAddress(); ok {
+			addresses = append(addresses, address)
+		}
+	}
+	logPath, _ := tunnelFilePath(definition.Name, ".log")
+	deadline := time.Now().Add(tunnelUpWaitTimeout)
+	for {
+		var pending []string
+		for _, address := range addresses {
+			if !isListening(address) {
+				pending = append(pending, address)
+			}
+		}
+		supervisorExited := false
+		select {
+		case <-exited:
+			supervisorExited = true
+		default:
+		}
+		switch {
+		case len(pending) == 0:
+			executor.logSuccess(definition.Name, fmt.Sprintf("已启动 (pid %d) %s", pid, strings.Join(addresses, ", ")))
+			return
+		case supervisorExited:
+			executor.logError(definition.Name, fmt.Sprintf("后台进程已退出, 查看日志: %s", logPath))
+			return
+		case time.Now().After(deadline):
+			executor.logWarning(definition.Name, fmt.Sprintf("已启动 (pid %d), 但 %s 尚未监听, 查看日志: %s", pid, strings.Join(pending, ", "), logPath))
+			return
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+}
+
+func (executor *TunnelExecutor) Down(cmd *cobra.Command, names []string) {
+	executor.initLogger(cmd)
+	if len(names) == 0 && !executor.All {
+		executor.logger.PrintfErr(logging.LogModeAppend, true, "请指定隧道名称或使用 --all")
+		os.Exit(1)
+	}
+	definitions, ok := executor.selectTunnels(names, executor.All)
+	for _, definition := range definitions {
+		if _, running := runningTunnelPid(definition.Name); !running {
+			if !executor.All {
+				executor.logSkip(definition.Name, "未在运行")
+			}
+			continue
+		}
+		if err := stopTunnel(definition.Name); err != nil {
+			executor.logError(definition.Name, err.Error())
+			ok = false
+			continue
+		}
+		executor.logSuccess(definition.Name, "已停止")
+	}
+	if !ok {
+		os.Exit(1)
+	}
+}
+
+// stopTunnel 通知后台进程退出并等待, 后台进程负责停止 ssh 并删除 PID 文件
+// 后台进程被强制结束时无法自行清理, 由此处结束状态文件中记录的 ssh 进程
+func stopTunnel(name string) error {
+	pid, running := runningTunnelPid(name)
+	if !running {
+		return nil
+	}
+	if err := terminateProcess(pid); err != nil {
+		return fmt.Errorf("停止后台进程失败 (pid %d)\n%w", pid, err)
+	}
+	if !supervisorStopsChild {
+		// 后台进程已退出, 状态文件中的 ssh 不会再被重启, ssh 可能已自行退出, 忽略错误
+		if state, ok := readTunnelState(name); ok && state.SSHPid != 0 {
+			_ = terminateProcess(state.SSHPid)
+		}
+	}
+	// 后台进程退出时释放隧道锁
+	deadline := time.Now().Add(tunnelDownWaitTimeout)
+	for {
+		if _, running := runningTunnelPid(name); !running {
+			break
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("后台进程未在 %s 内退出 (pid %d)", tunnelDownWaitTimeout, pid)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	removeTunnelRuntimeFiles(name, 0)
+	return nil
+}
+
+func (executor *TunnelExecutor) List(cmd *cobra.Command) {
+	executor.initLogger(cmd)
+	definitions, _ := executor.selectTunnels(nil, true)
+	if len(definitions) == 0 {
+		path, _ := configFilePath("tunnels.toml")
+		executor.logger.PrintfOut(logging.LogModeAppend, true, "未定义隧道, 使用 trance ssh tunnel add 添加或编辑 %s", path)
+		return
+	}
+	writer := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(writer, "NAME\tHOST\tSTATUS\tPID\tUPTIME\tRESTARTS\tPORTS")
+	for _, definition := range definitions {
+		status, pid, uptime, restarts := "stopped", "-", "-", "-"
+		running := false
+		if supervisorPid, ok := runningTunnelPid(definition.Name); ok {
+			running = true
+			status, pid = "connecting", strconv.Itoa(supervisorPid)
+			if state, ok := readTunnelState(definition.Name); ok {
+				restarts = strconv.Itoa(state.Restarts)
+				if state.SSHPid != 0 && !state.ConnectedAt.IsZero() {
+					status = "running"
+					uptime = formatUptime(time.Since(state.ConnectedAt))
+				}
+			}
+		}
+		_, _ = fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", definition.Name, definition.Host, status, pid, uptime, restarts, tunnelPorts(definition, running))
+	}
+	_ = writer.Flush()
+}
+
+// tunnelPorts 列出转发及其监听状态, 未运行时只列出转发
+func tunnelPorts(definition TunnelDefinition, running bool) string {
+	forwards, _ := parseForwards(definition.Forwards)
+	parts := make([]string, 0, len(forwards))
+	for _, forward := range forwards {
+		address, local := forward.listenAddress()
+		switch {
+		case !running:
+			parts = append(parts, forward.Spec())
+		case !local:
+			parts = append(parts, forward.Spec()+" (remote)")
+		case isListening(address):
+			parts = append(parts, address+" (listening)")
+		default:
+			parts = append(parts, address+" (closed)")
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+func formatUptime(duration time.Duration) string {
+	duration = duration.Round(time.Second)
+	if days := duration / (24 * time.Hour); days > 0 {
+		return fmt.Sprintf("%dd%s", days, (duration % (24 * time.Hour)).String())
+	}
+	return duration.String()
+}
+
+func (executor *TunnelExecutor) Status(cmd *cobra.Command, names []string) {
+	executor.initLogger(cmd)
+	definitions, ok := executor.selectTunnels(names, false)
+	labelStyle := color.New(color.FgGray)
+	for i, definition := range definitions {
+		if i > 0 {
+			executor.logger.PrintfOut(logging.LogModeAppend, true, "")
+		}
+		args, _, _ := definition.sshArgs()
+		rows := [][2]string{
+			{"Name:", definition.Name},
+			{"Command:", "ssh " + shellJoin(args)},
+		}
+		supervisorPid, running := runningTunnelPid(definition.Name)
+		if !running {
+			rows = append(rows, [2]string{"Status:", "stopped"})
+		} else {
+			rows = append(rows, [2]string{"Status:", fmt.Sprintf("running (pid %d)", supervisorPid)})
+			if state, ok := readTunnelState(definition.Name); ok {
+				rows = append(rows, [2]string{"Started:", state.StartedAt.Format(time.DateTime)})
+				if state.SSHPid != 0 && !state.ConnectedAt.IsZero() {
+					rows = append(rows, [2]string{"Connected:", fmt.Sprintf("%s (ssh pid %d, up %s)", state.ConnectedAt.Format(time.DateTime), state.SSHPid, formatUptime(time.Since(state.ConnectedAt)))})
+				} else {
+					rows = append(rows, [2]string{"Connected:", "reconnecting"})
+				}
+				rows = append(rows, [2]string{"Restarts:", strconv.Itoa(state.Restarts)})
+				if state.LastExit != "" {
+					rows = append(rows, [2]string{"Last Exit:", state.LastExit})
+				}
+			}
+		}
+		rows = append(rows, [2]string{"Ports:", tunnelPorts(definition, running)})
+		logPath, _ := tunnelFilePath(definition.Name, ".log")
+		rows = append(rows, [2]string{"Log:", logPath})
+		for _, row := range rows {
+			executor.logger.PrintfOut(logging.LogModeAppend, true, "%s %s", labelStyle.Sprintf("%-11s", row[0]), row[1])
+		}
+		for _, line := range tailLines(logPath, tunnelStatusLogLines) {
+			executor.logger.PrintfOut(logging.LogModeAppend, true, "  %s", line)
+		}
+	}
+	if !ok {
+		os.Exit(1)
+	}
+}
+
+// tailLines 返回文件末尾的若干行, 文件不存在时返回空列表
+func tailLines(path string, count int) []string {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer func(file *os.File) {
+		_ = file.Close()
+	}(file)
+	var lines []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+		if len(lines) > count {
+			lines = lines[1:]
+		}
+	}
+	return lines
+}
+
+func (executor *TunnelExecutor) Add(cmd *cobra.Command, args []string) {
+	executor.initLogger(cmd)
+	positional := args
+	var sshOptions []string
+	if dashIndex := cmd.ArgsLenAtDash(); dashIndex != -1 {
+		positional, sshOptions = args[:dashIndex], args[dashIndex:]
+	}
+	if len(positional) != 2 {
+		executor.logger.PrintfErr(logging.LogModeAppend, true, "需要隧道名称与主机两个参数")
+		os.Exit(1)
+	}
+	definition := TunnelDefinition{
+		Name:       positional[0],
+		Host:       positional[1],
+		ProxyJump:  executor.ProxyJump,
+		SshOptions: sshOptions,
+		Forwards:   executor.Forwards,
+	}
+	if err := definition.validate(); err != nil {
+		executor.logger.PrintfErr(logging.LogModeAppend, true, "%s", err.Error())
+		os.Exit(1)
+	}
+	definitions, err := loadTunnels()
+	if err != nil {
+		executor.logger.PrintfErr(logging.LogModeAppend, true, "%s", err.Error())
+		os.Exit(1)
+	}
+	if index := slices.IndexFunc(definitions, func(existing TunnelDefinition) bool { return existing.Name == definition.Name }); index != -1 {
+		if !executor.Force {
+			executor.logError(definition.Name, "隧道已存在, 使用 --force 替换")
+			os.Exit(1)
+		}
+		definitions[index] = definition
+	} else {
+		definitions = append(definitions, definition)
+	}
+	if err := saveTunnels(definitions); err != nil {
+		executor.logger.PrintfErr(logging.LogModeAppend, true, "%s", err.Error())
+		os.Exit(1)
+	}
+	executor.logSuccess(definition.Name, "已保存, 使用 trance ssh tunnel up "+definition.Name+" 启动")
+	if _, running := runningTunnelPid(definition.Name); running {
+		executor.logWarning(definition.Name, "隧道正在运行, 重新启动后生效")
+	}
+}
+
+func (executor *TunnelExecutor) Remove(cmd *cobra.Command, names []string) {
+	executor.initLogger(cmd)
+	definitions, err := loadTunnels()
+	if err != nil {
+		executor.logger.PrintfErr(logging.LogModeAppend, true, "%s", err.Error())
+		os.Exit(1)
+	}
+	ok := true
+	for _, name := range names {
+		index := slices.IndexFunc(definitions, func(definition TunnelDefinition) bool { return definition.Name == name })
+		if index == -1 {
+			executor.logError(name, "隧道不存在")
+			ok = false
+			continue
+		}
+		if err := stopTunnel(name); err != nil {
+			executor.logError(name, err.Error())
+			ok = false
+			continue
+		}
+		definitions = slices.Delete(definitions, index, index+1)
+		if logPath, err := tunnelFilePath(name, ".log"); err == nil {
+			_ = os.Remove(logPath)
+		}
+		executor.logSuccess(name, "已删除")
+	}
+	if err := saveTunnels(definitions); err != nil {
+		executor.logger.PrintfErr(logging.LogModeAppend, true, "%s", err.Error())
+		os.Exit(1)
+	}
+	if !ok {
+		os.Exit(1)
+	}
+}
+
+// Supervise 在后台运行隧道, 断开后按指数退避重连, 收到 SIGTERM 时停止 ssh 并清理 PID 与状态文件
+func (executor *TunnelExecutor) Supervise(cmd *cobra.Command, name string) {
+	executor.initLogger(cmd)
+	definitions, err := loadTunnels()
+	if err != nil {
+		executor.logTimestamped("%s", err.Error())
+		os.Exit(1)
+	}
+	definition, ok := findTunnel(definitions, name)
+	if !ok {
+		executor.logTimestamped("隧道不存在: %s", name)
+		os.Exit(1)
+	}
+	args, _, err := definition.sshArgs()
+	if err != nil {
+		executor.logTimestamped("%s", err.Error())
+		os.Exit(1)
+	}
+	// 持有隧道锁直到退出, 其他命令以此确认 PID 文件属于当前进程
+	lock, err := acquireTunnelLock(name)
+	if err != nil {
+		executor.logTimestamped("%s", err.Error())
+		os.Exit(1)
+	}
+	defer func(file *os.File) {
+		_ = file.Close()
+	}(lock)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	state := tunnelState{Pid: os.Getpid(), StartedAt: time.Now()}
+	defer removeTunnelRuntimeFiles(name, state.Pid)
+	executor.logTimestamped("启动: ssh %s", shellJoin(args))
+
+	backoff := tunnelMinBackoff
+	for {
+		startTime := time.Now()
+		sshCmd := exec.Command("ssh", args...)
+		sshCmd.Stdout = os.Stdout
+		sshCmd.Stderr = os.Stderr
+		if err := sshCmd.Start(); err != nil {
+			executor.logTimestamped("启动 ssh 失败: %s", err.Error())
+			return
+		}
+		state.SSHPid, state.ConnectedAt = sshCmd.Process.Pid, startTime
+		if err := writeTunnelState(name, state); err != nil {
+			executor.logTimestamped("%s", err.Error())
+		}
+		var waitErr error
+		done := make(chan struct{})
+		go func() {
+			waitErr = sshCmd.Wait()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-ctx.Done():
+			if err := sshCmd.Process.Signal(syscall.SIGTERM); err != nil {
+				_ = sshCmd.Process.Kill()
+			}
+			<-done
+			executor.logTimestamped("已停止")
+			return
+		}
+		if time.Since(startTime) >= tunnelStableDuration {
+			backoff = tunnelMinBackoff
+		}
+		status := "正常退出"
+		var exitErr *exec.ExitError
+		if errors.As(waitErr, &exitErr) {
+			status = fmt.Sprintf("退出码 %d", exitErr.ExitCode())
+		} else if waitErr != nil {
+			status = waitErr.Error()
+		}
+		state.LastExit = status + " @ " + time.Now().Format(time.DateTime)
+		state.SSHPid, state.ConnectedAt = 0, time.Time{}
+		state.Restarts++
+		if err := writeTunnelState(name, state); err != nil {
+			executor.logTimestamped("%s", err.Error())
+		}
+		executor.logTimestamped("连接已断开 (%s), %s 后重连", status, backoff)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			executor.logTimestamped("已停止")
+			return
+		}
+		backoff = min(backoff*2, tunnelMaxBackoff)
+	}
+}
+
+// logTimestamped 后台进程的日志带时间戳写入隧道日志
+func (executor *TunnelExecutor) logTimestamped(format string, a ...any) {
+	executor.logger.PrintfOut(logging.LogModeAppend, true, "%s %s", time.Now().Format(time.DateTime), fmt.Sprintf(format, a...))
+}
+
+func (executor *TunnelExecutor) logInProgress(name string, message string) {
+	inProgressColor := color.New(color.FgCyan, color.Bold)
+	executor.logger.PrintfOut(logging.LogModeInPlace, false, "%s %s: %s", inProgressColor.Sprintf("[>]"), name, message)
+}
+
+func (executor *TunnelExecutor) logSuccess(name string, message string) {
+	successColor := color.New(color.FgGreen, color.Bold)
+	executor.logger.PrintfOut(logging.LogModeInPlace, true, "%s %s: %s", successColor.Sprintf("[O]"), name, message)
+}
+
+func (executor *TunnelExecutor) logSkip(name string, message string) {
+	skipColor := color.New(color.FgYellow, color.Bold)
+	executor.logger.PrintfOut(logging.LogModeInPlace, true, "%s %s: %s", skipColor.Sprintf("[-]"), name, message)
+}
+
+func (executor *TunnelExecutor) logWarning(name string, message string) {
+	warningColor := color.New(color.FgYellow, color.Bold)
+	executor.logger.PrintfErr(logging.LogModeAppend, true, "%s %s: %s", warningColor.Sprintf("[!]"), name, message)
+}
+
+func (executor *TunnelExecutor) logError(name string, message string) {
+	errorColor := color.New(color.FgRed, color.Bold)
+	executor.logger.PrintfErr(logging.LogModeAppend, true, "%s %s: %s", errorColor.Sprintf("[X]"), name, message)
+}
@@ -0,0 +1,249 @@
+package ssh
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+type tunnelDocument struct {
+	Tunnel []TunnelDefinition `toml:"tunnel"`
+}
+
+// TunnelDefinition 为 tunnels.toml 中的一个命名隧道, Host 为别名或 user@host:port
+type TunnelDefinition struct {
+	Name       string   `toml:"name"`
+	Host       string   `toml:"host"`
+	ProxyJump  string   `toml:"proxy_jump,omitempty"`
+	SshOptions []string `toml:"ssh_options,omitempty"`
+	Forwards   []string `toml:"forwards"`
+}
+
+// tunnelState 由后台进程维护, 记录当前连接与重连情况
+type tunnelState struct {
+	Pid         int       `json:"pid"`
+	SSHPid      int       `json:"ssh_pid,omitempty"`
+	StartedAt   time.Time `json:"started_at"`
+	ConnectedAt time.Time `json:"connected_at,omitempty"`
+	Restarts    int       `json:"restarts"`
+	LastExit    string    `json:"last_exit,omitempty"`
+}
+
+// loadTunnels 读取 tunnels.toml, 文件不存在时返回空列表
+func loadTunnels() ([]TunnelDefinition, error) {
+	path, err := configFilePath("tunnels.toml")
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("读取隧道配置失败: %s\n%w", path, err)
+	}
+	var document tunnelDocument
+	if err := toml.Unmarshal(data, &document); err != nil {
+		return nil, fmt.Errorf("解析隧道配置失败: %s\n%w", path, err)
+	}
+	for _, definition := range document.Tunnel {
+		if err := definition.validate(); err != nil {
+			return nil, fmt.Errorf("解析隧道配置失败: %s\n%w", path, err)
+		}
+	}
+	return document.Tunnel, nil
+}
+
+func saveTunnels(definitions []TunnelDefinition) error {
+	path, err := configFilePath("tunnels.toml")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("创建配置目录失败\n%w", err)
+	}
+	data, err := toml.Marshal(tunnelDocument{Tunnel: definitions})
+	if err != nil {
+		return err
+	}
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0o600); err != nil {
+		return fmt.Errorf("写入隧道配置失败: %s\n%w", tmpPath, err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("写入隧道配置失败: %s\n%w", path, err)
+	}
+	return nil
+}
+
+func findTunnel(definitions []TunnelDefinition, name string) (TunnelDefinition, bool) {
+	index := slices.IndexFunc(definitions, func(definition TunnelDefinition) bool { return definition.Name == name })
+	if index == -1 {
+		return TunnelDefinition{}, false
+	}
+	return definitions[index], true
+}
+
+func (definition TunnelDefinition) validate() error {
+	if definition.Name == "" || strings.ContainsAny(definition.Name, "/\\") || strings.HasPrefix(definition.Name, ".") {
+		return fmt.Errorf("隧道名称无效: %q", definition.Name)
+	}
+	if definition.Host == "" {
+		return fmt.Errorf("隧道缺少 host: %s", definition.Name)
+	}
+	if len(definition.Forwards) == 0 {
+		return fmt.Errorf("隧道缺少端口转发: %s", definition.Name)
+	}
+	if _, err := parseForwards(definition.Forwards); err != nil {
+		return fmt.Errorf("隧道 %s 的%w", definition.Name, err)
+	}
+	return nil
+}
+
+// sshArgs 返回后台运行所需的完整 ssh 参数, 后台进程无法输入密码, 使用 BatchMode
+func (definition TunnelDefinition) sshArgs() ([]string, []Forward, error) {
+	forwards, err := parseForwards(definition.Forwards)
+	if err != nil {
+		return nil, nil, err
+	}
+	usr, server, port := parseHostString(definition.Host)
+	options := slices.Concat(tunnelSSHOptions, []string{"-o", "BatchMode=yes"}, forwardArgs(forwards), definition.SshOptions)
+	return buildSSHArgs(options, usr, server, port, definition.ProxyJump, nil), forwards, nil
+}
+
+// tunnelFilePath 返回 $XDG_STATE_HOME/trance/ssh/tunnels 下的 PID、状态与日志文件
+func tunnelFilePath(name string, ext string) (string, error) {
+	return xdgPath("XDG_STATE_HOME", filepath.Join(".local", "state"), filepath.Join("tunnels", name+ext))
+}
+
+// runningTunnelPid 在后台进程持有隧道锁时读取其 PID
+// 锁未被持有时 PID 文件已过期, 其中的 PID 可能已被其他进程复用, 清理 PID 与状态文件
+func runningTunnelPid(name string) (int, bool) {
+	lockPath, err := tunnelFilePath(name, ".lock")
+	if err != nil {
+		return 0, false
+	}
+	lock, acquired, err := tryLockFile(lockPath)
+	if err != nil {
+		return 0, false
+	}
+	if acquired {
+		removeTunnelRuntimeFiles(name, 0)
+		_ = lock.Close()
+		return 0, false
+	}
+	// 后台进程获取锁后才写入 PID 文件, 启动中尚未写入时视为未运行
+	pidPath, err := tunnelFilePath(name, ".pid")
+	if err != nil {
+		return 0, false
+	}
+	data, err := os.ReadFile(pidPath)
+	if err != nil {
+		return 0, false
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || pid <= 0 {
+		return 0, false
+	}
+	return pid, true
+}
+
+// acquireTunnelLock 由后台进程获取隧道锁并写入 PID 文件, 锁在进程退出前一直持有
+// 其他命令检查状态时会短暂持有锁, 因此在 tunnelLockTimeout 内重试
+func acquireTunnelLock(name string) (*os.File, error) {
+	lockPath, err := tunnelFilePath(name, ".lock")
+	if err != nil {
+		return nil, err
+	}
+	deadline := time.Now().Add(tunnelLockTimeout)
+	for {
+		lock, acquired, err := tryLockFile(lockPath)
+		if err != nil {
+			return nil, fmt.Errorf("获取隧道锁失败: %s\n%w", lockPath, err)
+		}
+		if acquired {
+			pidPath, err := tunnelFilePath(name, ".pid")
+			if err == nil {
+				err = os.WriteFile(pidPath, []byte(strconv.Itoa(os.Getpid())+"\n"), 0o600)
+			}
+			if err != nil {
+				_ = lock.Close()
+				return nil, fmt.Errorf("写入 PID 文件失败\n%w", err)
+			}
+			return lock, nil
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("隧道已在运行: %s", name)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// removeTunnelRuntimeFiles 删除 PID 与状态文件, pid 非 0 时只删除属于该进程的文件
+func removeTunnelRuntimeFiles(name string, pid int) {
+	pidPath, err := tunnelFilePath(name, ".pid")
+	if err != nil {
+		return
+	}
+	if pid != 0 {
+		data, err := os.ReadFile(pidPath)
+		if err != nil || strings.TrimSpace(string(data)) != strconv.Itoa(pid) {
+			return
+		}
+	}
+	_ = os.Remove(pidPath)
+	if statePath, err := tunnelFilePath(name, ".json"); err == nil {
+		_ = os.Remove(statePath)
+	}
+}
+
+func readTunnelState(name string) (tunnelState, bool) {
+	statePath, err := tunnelFilePath(name, ".json")
+	if err != nil {
+		return tunnelState{}, false
+	}
+	data, err := os.ReadFile(statePath)
+	if err != nil {
+		return tunnelState{}, false
+	}
+	var state tunnelState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return tunnelState{}, false
+	}
+	return state, true
+}
+
+func writeTunnelState(name string, state tunnelState) error {
+	statePath, err := tunnelFilePath(name, ".json")
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	tmpPath := statePath + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0o600); err != nil {
+		return fmt.Errorf("写入隧道状态失败: %s\n%w", tmpPath, err)
+	}
+	return os.Rename(tmpPath, statePath)
+}
+
+// isListening 尝试连接本地转发端口
+func isListening(address string) bool {
+	conn, err := net.DialTimeout("tcp", address, 200*time.Millisecond)
+	if err != nil {
+		return false
+	}
+	_ = conn.Close()
+	return true
+}
@@ -0,0 +1,57 @@
+package imgfmt
+
+import (
+	"fmt"
+	"path/filepath"
+	"trance-cli/internal/logging"
+
+	"github.com/gookit/color"
+)
+
+// Acceptor 按文件内容筛选 img 命令待处理的文件, 扩展名与内容不一致时给出警告或修正扩展名
+type Acceptor struct {
+	Logger    *logging.Logger
+	Supported []Format
+	Verbose   bool
+	// 修正扩展名会重命名文件, 只读与预览模式下应保持关闭
+	FixExt bool
+}
+
+// Accept 返回处理时使用的路径与识别的格式, explicit 为命令行中直接指定的文件
+func (acceptor Acceptor) Accept(path string, explicit bool) (string, Format, bool) {
+	inspection, supported, err := Inspect(path, acceptor.Supported)
+	if err != nil {
+		acceptor.logError(path, fmt.Sprintf("无法读取文件\n%v", err))
+		return "", Unknown, false
+	}
+	if !supported {
+		if explicit && acceptor.Verbose {
+			acceptor.logError(path, "跳过不支持的文件类型")
+		}
+		return "", Unknown, false
+	}
+	if !inspection.Mismatch {
+		return path, inspection.Format, true
+	}
+	if !acceptor.FixExt {
+		acceptor.logWarning(path, fmt.Sprintf("扩展名与文件内容 (%s) 不一致", inspection.Format))
+		return path, inspection.Format, true
+	}
+	newPath, err := FixExtension(path, inspection.Format)
+	if err != nil {
+		acceptor.logError(path, fmt.Sprintf("无法修正扩展名\n%v", err))
+		return "", Unknown, false
+	}
+	acceptor.logWarning(path, fmt.Sprintf("已修正扩展名: %s", filepath.Base(newPath)))
+	return newPath, inspection.Format, true
+}
+
+func (acceptor Acceptor) logWarning(path string, message string) {
+	warningColor := color.New(color.FgYellow, color.Bold)
+	acceptor.Logger.PrintfErr(logging.LogModeAppend, true, "%s %s: %s", warningColor.Sprintf("[!]"), path, message)
+}
+
+func (acceptor Acceptor) logError(path string, message string) {
+	errorColor := color.New(color.FgRed, color.Bold)
+	acceptor.Logger.PrintfErr(logging.LogModeAppend, true, "%s %s: %s", errorColor.Sprintf("[X]"), path, message)
+}
@@ -0,0 +1,68 @@
+package imgfmt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+)
+
+var (
+	jxlCodestreamSignature = []byte{0xFF, 0x0A}
+	jxlContainerSignature  = []byte{0x00, 0x00, 0x00, 0x0C, 'J', 'X', 'L', ' ', 0x0D, 0x0A, 0x87, 0x0A}
+)
+
+// HasJPEGReconstruction 检查 JXL 容器中是否存在 jbrd 盒, 存在即可逐字节还原原始 JPEG
+func HasJPEGReconstruction(path string) (bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer func(file *os.File) {
+		_ = file.Close()
+	}(file)
+
+	signature := make([]byte, len(jxlContainerSignature))
+	n, err := io.ReadFull(file, signature)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return false, fmt.Errorf("JXL 文件头不完整\n%w", err)
+	}
+	// 裸码流不含容器盒
+	if bytes.HasPrefix(signature[:n], jxlCodestreamSignature) {
+		return false, nil
+	}
+	if !bytes.Equal(signature[:n], jxlContainerSignature) {
+		return false, fmt.Errorf("不是有效的 JXL 文件")
+	}
+	boxHeader := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(file, boxHeader); err != nil {
+			return false, nil
+		}
+		headerSize := int64(8)
+		boxSize := int64(binary.BigEndian.Uint32(boxHeader[0:4]))
+		boxType := string(boxHeader[4:8])
+		if boxType == "jbrd" {
+			return true, nil
+		}
+		switch boxSize {
+		case 0:
+			// 盒延伸至文件末尾
+			return false, nil
+		case 1:
+			largeSize := make([]byte, 8)
+			if _, err := io.ReadFull(file, largeSize); err != nil {
+				return false, nil
+			}
+			headerSize += 8
+			boxSize = int64(binary.BigEndian.Uint64(largeSize))
+		}
+		if boxSize < headerSize {
+			return false, fmt.Errorf("JXL 容器盒大小无效: %s", boxType)
+		}
+		if _, err := file.Seek(boxSize-headerSize, io.SeekCurrent); err != nil {
+			return false, nil
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package imgfmt
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+type Format string
+
+const (
+	Unknown Format = ""
+	JPEG    Format = "jpeg"
+	PNG     Format = "png"
+	GIF     Format = "gif"
+	BMP     Format = "bmp"
+	TIFF    Format = "tiff"
+	WebP    Format = "webp"
+	JXL     Format = "jxl"
+)
+
+// AllFormats 为 img 命令可识别的全部格式
+var AllFormats = []Format{JPEG, PNG, GIF, BMP, TIFF, WebP, JXL}
+
+// 识别格式所需的最大文件头长度
+const headerSize = 14
+
+// Ext 返回规范扩展名
+func (format Format) Ext() string {
+	switch format {
+	case JPEG:
+		return ".jpg"
+	case Unknown:
+		return ""
+	}
+	return "." + string(format)
+}
+
+// MatchesExt 判断路径扩展名是否与格式一致
+func (format Format) MatchesExt(path string) bool {
+	return FromExt(path) == format
+}
+
+// FromExt 按扩展名推断格式
+func FromExt(path string) Format {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".jpg", ".jpeg", ".jpe", ".jfif":
+		return JPEG
+	case ".png":
+		return PNG
+	case ".gif":
+		return GIF
+	case ".bmp":
+		return BMP
+	case ".tif", ".tiff":
+		return TIFF
+	case ".webp":
+		return WebP
+	case ".jxl":
+		return JXL
+	}
+	return Unknown
+}
+
+// Detect 按文件头魔数识别格式
+func Detect(header []byte) Format {
+	switch {
+	case bytes.HasPrefix(header, []byte{0xFF, 0xD8, 0xFF}):
+		return JPEG
+	case bytes.HasPrefix(header, []byte("\x89PNG\r\n\x1a\n")):
+		return PNG
+	case bytes.HasPrefix(header, []byte("GIF87a")), bytes.HasPrefix(header, []byte("GIF89a")):
+		return GIF
+	// BMP 文件头保留字段恒为 0, 用于与以 BM 开头的文本区分
+	case bytes.HasPrefix(header, []byte("BM")) && len(header) >= 14 && bytes.Equal(header[6:10], []byte{0, 0, 0, 0}):
+		return BMP
+	case bytes.HasPrefix(header, []byte("II*\x00")), bytes.HasPrefix(header, []byte("MM\x00*")):
+		return TIFF
+	case len(header) >= 12 && bytes.Equal(header[0:4], []byte("RIFF")) && bytes.Equal(header[8:12], []byte("WEBP")):
+		return WebP
+	case bytes.HasPrefix(header, jxlCodestreamSignature), bytes.HasPrefix(header, jxlContainerSignature):
+		return JXL
+	}
+	return Unknown
+}
+
+// Sniff 读取文件头识别格式
+func Sniff(path string) (Format, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return Unknown, err
+	}
+	defer func(file *os.File) {
+		_ = file.Close()
+	}(file)
+	header := make([]byte, headerSize)
+	n, err := io.ReadFull(file, header)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return Unknown, err
+	}
+	return Detect(header[:n]), nil
+}
+
+// Inspection 为内容识别结果
+type Inspection struct {
+	Format Format
+	// 扩展名与内容不一致
+	Mismatch bool
+}
+
+// Inspect 识别文件格式, 并判断是否属于 supported
+func Inspect(path string, supported []Format) (Inspection, bool, error) {
+	format, err := Sniff(path)
+	if err != nil {
+		return Inspection{}, false, err
+	}
+	inspection := Inspection{
+		Format:   format,
+		Mismatch: format != Unknown && !format.MatchesExt(path),
+	}
+	return inspection, format != Unknown && slices.Contains(supported, format), nil
+}
+
+// FixExtension 将文件重命名为与内容一致的扩展名, 返回新路径
+func FixExtension(path string, format Format) (string, error) {
+	if format == Unknown {
+		return "", fmt.Errorf("无法识别文件格式")
+	}
+	// 未知扩展名 (如下载文件的 .php/.bin) 同样替换, 避免产生 x.php.jpg 这类双重扩展名
+	// 无扩展名与以点开头的文件名 (如 .image) 直接追加扩展名
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(path, ext)
+	if ext == filepath.Base(path) {
+		base = path
+	}
+	newPath := base + format.Ext()
+	if newPath == path {
+		return path, nil
+	}
+	if _, err := os.Lstat(newPath); err == nil {
+		return "", fmt.Errorf("目标文件已存在: %s", newPath)
+	}
+	if err := os.Rename(path, newPath); err != nil {
+		return "", err
+	}
+	return newPath, nil
+}
@@ -46,7 +46,7 @@ func (logger *Logger) PrintfOut(mode LogMode, endLF bool, format string, a ...in
 			_, _ = fmt.Fprintf(logger.ErrWriter, "\n")
 		}
 	}
-	_, _ = fmt.Fprintf(logger.OutWriter, message)
+	_, _ = fmt.Fprint(logger.OutWriter, message)
 	if endLF {
 		_, _ = fmt.Fprintf(logger.OutWriter, "\n")
 		logger.State = LoggerStateNewLine
@@ -75,7 +75,7 @@ func (logger *Logger) PrintfErr(mode LogMode, endLF bool, format string, a ...in
 			_, _ = fmt.Fprintf(logger.ErrWriter, "\n")
 		}
 	}
-	_, _ = fmt.Fprintf(logger.ErrWriter, message)
+	_, _ = fmt.Fprint(logger.ErrWriter, message)
 	if endLF {
 		_, _ = fmt.Fprintf(logger.ErrWriter, "\n")
 		logger.State = LoggerStateNewLine
@@ -0,0 +1,127 @@
+package metadata
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Category 描述一类元数据, 以及 exiftool 删除/保留该类时使用的参数
+type Category struct {
+	Name string
+	// 匹配 exiftool family 0/1 分组名
+	Groups []string
+	// 匹配标签名, 支持 * ? 通配
+	Tags []string
+	// 单独删除该类时追加的参数
+	DeleteArgs []string
+	// -all= 之后需排除删除的参数, 用于独立存储的分组
+	KeepExcludeArgs []string
+	// -all= 之后通过 -tagsfromfile @ 复制回来的参数
+	KeepCopyArgs []string
+}
+
+var Categories = []Category{
+	{
+		Name:            "icc",
+		Groups:          []string{"ICC_Profile"},
+		DeleteArgs:      []string{"-icc_profile:all="},
+		KeepExcludeArgs: []string{"--icc_profile:all"},
+	},
+	{
+		Name:         "orientation",
+		Tags:         []string{"Orientation"},
+		DeleteArgs:   []string{"-Orientation="},
+		KeepCopyArgs: []string{"-Orientation"},
+	},
+	{
+		Name:         "copyright",
+		Tags:         []string{"Copyright", "CopyrightNotice", "Rights"},
+		DeleteArgs:   []string{"-Copyright=", "-CopyrightNotice=", "-Rights="},
+		KeepCopyArgs: []string{"-Copyright", "-CopyrightNotice", "-Rights"},
+	},
+	{
+		Name:         "gps",
+		Groups:       []string{"GPS"},
+		Tags:         []string{"GPS*"},
+		DeleteArgs:   []string{"-gps:all=", "-xmp:gps*="},
+		KeepCopyArgs: []string{"-gps:all", "-xmp:gps*"},
+	},
+	{
+		Name:         "serial",
+		Tags:         []string{"*SerialNumber"},
+		DeleteArgs:   []string{"-*SerialNumber="},
+		KeepCopyArgs: []string{"-*SerialNumber"},
+	},
+	{
+		Name:         "maker-notes",
+		Groups:       []string{"MakerNotes"},
+		DeleteArgs:   []string{"-makernotes:all="},
+		KeepCopyArgs: []string{"-MakerNotes"},
+	},
+	{
+		Name:         "owner",
+		Tags:         []string{"OwnerName", "CameraOwnerName", "Artist", "Author", "Creator", "By-line"},
+		DeleteArgs:   []string{"-OwnerName=", "-CameraOwnerName=", "-Artist=", "-Author=", "-Creator=", "-By-line="},
+		KeepCopyArgs: []string{"-OwnerName", "-CameraOwnerName", "-Artist", "-Author", "-Creator", "-By-line"},
+	},
+	{
+		Name:         "datetime",
+		Tags:         []string{"DateTimeOriginal", "CreateDate", "ModifyDate", "DateCreated", "TimeCreated", "DigitalCreationDate", "DigitalCreationTime", "SubSecTime*", "OffsetTime*"},
+		DeleteArgs:   []string{"-DateTimeOriginal=", "-CreateDate=", "-ModifyDate=", "-DateCreated=", "-TimeCreated=", "-DigitalCreationDate=", "-DigitalCreationTime=", "-SubSecTime*=", "-OffsetTime*="},
+		KeepCopyArgs: []string{"-DateTimeOriginal", "-CreateDate", "-ModifyDate", "-DateCreated", "-TimeCreated", "-DigitalCreationDate", "-DigitalCreationTime", "-SubSecTime*", "-OffsetTime*"},
+	},
+	{
+		Name:         "thumbnail",
+		Tags:         []string{"ThumbnailImage", "ThumbnailTIFF", "PreviewImage", "JpgFromRaw"},
+		DeleteArgs:   []string{"-ThumbnailImage=", "-ThumbnailTIFF=", "-PreviewImage=", "-JpgFromRaw="},
+		KeepCopyArgs: []string{"-ThumbnailImage", "-ThumbnailTIFF", "-PreviewImage", "-JpgFromRaw"},
+	},
+}
+
+// CategoryNames 返回全部分类名, 用于帮助信息
+func CategoryNames() []string {
+	names := make([]string, len(Categories))
+	for i, category := range Categories {
+		names[i] = category.Name
+	}
+	return names
+}
+
+// LookupCategories 按名称查找分类, 名称不区分大小写
+func LookupCategories(names []string) ([]Category, error) {
+	var result []Category
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		found := false
+		for _, category := range Categories {
+			if category.Name == name {
+				result = append(result, category)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("未知元数据分类: %s (可选: %s)", name, strings.Join(CategoryNames(), ", "))
+		}
+	}
+	return result, nil
+}
+
+func (category Category) Match(tag Tag) bool {
+	for _, group := range category.Groups {
+		if strings.EqualFold(group, tag.Group0) || strings.EqualFold(group, tag.Group1) {
+			return true
+		}
+	}
+	tagName := strings.ToLower(tag.Name)
+	for _, pattern := range category.Tags {
+		if ok, _ := path.Match(strings.ToLower(pattern), tagName); ok {
+			return true
+		}
+	}
+	return false
+}
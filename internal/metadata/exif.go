@@ -0,0 +1,223 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	exifTagOrientation      = 0x0112
+	exifTagExifIFDPointer   = 0x8769
+	exifTagDateTimeOriginal = 0x9003
+)
+
+// EXIF 日期时间格式, 不含时区
+const exifDateTimeLayout = "2006:01:02 15:04:05"
+
+var exifHeader = []byte("Exif\x00\x00")
+
+type exifEntry struct {
+	Type  uint16
+	Count uint32
+	// 值不超过 4 字节时为值本身, 否则为偏移
+	ValueOrOffset []byte
+}
+
+// EXIF 为解析后的 TIFF 结构, 仅包含 IFD0 与 Exif 子 IFD
+type EXIF struct {
+	byteOrder binary.ByteOrder
+	raw       []byte
+	ifd0      map[uint16]exifEntry
+	exifIFD   map[uint16]exifEntry
+}
+
+// ExtractEXIF 从 JPEG/PNG/WebP/TIFF 数据中取出 TIFF 结构的 EXIF 数据, 不存在时返回 nil
+func ExtractEXIF(data []byte) []byte {
+	switch {
+	case bytes.HasPrefix(data, jpegSignature):
+		return extractJPEGEXIF(data)
+	case bytes.HasPrefix(data, pngSignature):
+		return extractPNGEXIF(data)
+	case len(data) >= 12 && string(data[0:4]) == "RIFF" && string(data[8:12]) == "WEBP":
+		return extractWebPEXIF(data)
+	case bytes.HasPrefix(data, []byte("II*\x00")), bytes.HasPrefix(data, []byte("MM\x00*")):
+		return data
+	}
+	return nil
+}
+
+func extractJPEGEXIF(data []byte) []byte {
+	pos := len(jpegSignature)
+	for pos+4 <= len(data) {
+		if data[pos] != 0xFF {
+			return nil
+		}
+		marker := data[pos+1]
+		if marker == 0xFF {
+			pos++
+			continue
+		}
+		if marker == 0x01 || (marker >= 0xD0 && marker <= 0xD7) {
+			pos += 2
+			continue
+		}
+		// 扫描数据之后不再有元数据段
+		if marker == 0xDA || marker == 0xD9 {
+			return nil
+		}
+		length := int(binary.BigEndian.Uint16(data[pos+2 : pos+4]))
+		segmentEnd := pos + 2 + length
+		if length < 2 || segmentEnd > len(data) {
+			return nil
+		}
+		payload := data[pos+4 : segmentEnd]
+		if marker == 0xE1 && bytes.HasPrefix(payload, exifHeader) {
+			return payload[len(exifHeader):]
+		}
+		pos = segmentEnd
+	}
+	return nil
+}
+
+func extractPNGEXIF(data []byte) []byte {
+	pos := len(pngSignature)
+	for pos+8 <= len(data) {
+		length := int(binary.BigEndian.Uint32(data[pos : pos+4]))
+		chunkType := string(data[pos+4 : pos+8])
+		chunkEnd := pos + 12 + length
+		if chunkEnd > len(data) {
+			return nil
+		}
+		switch chunkType {
+		case "eXIf":
+			return bytes.TrimPrefix(data[pos+8:pos+8+length], exifHeader)
+		case "IEND":
+			return nil
+		}
+		pos = chunkEnd
+	}
+	return nil
+}
+
+func extractWebPEXIF(data []byte) []byte {
+	pos := 12
+	for pos+8 <= len(data) {
+		fourCC := string(data[pos : pos+4])
+		size := int(binary.LittleEndian.Uint32(data[pos+4 : pos+8]))
+		if pos+8+size > len(data) {
+			return nil
+		}
+		if fourCC == "EXIF" {
+			return bytes.TrimPrefix(data[pos+8:pos+8+size], exifHeader)
+		}
+		pos += 8 + size + size&1
+	}
+	return nil
+}
+
+// ParseEXIF 解析 TIFF 结构的 EXIF 数据
+func ParseEXIF(raw []byte) (*EXIF, error) {
+	if len(raw) < 8 {
+		return nil, fmt.Errorf("EXIF 数据过短")
+	}
+	exif := &EXIF{raw: raw}
+	switch string(raw[0:2]) {
+	case "II":
+		exif.byteOrder = binary.LittleEndian
+	case "MM":
+		exif.byteOrder = binary.BigEndian
+	default:
+		return nil, fmt.Errorf("EXIF 字节序标记无效")
+	}
+	if exif.byteOrder.Uint16(raw[2:4]) != 42 {
+		return nil, fmt.Errorf("EXIF TIFF 标记无效")
+	}
+	ifd0, err := exif.readIFD(exif.byteOrder.Uint32(raw[4:8]))
+	if err != nil {
+		return nil, err
+	}
+	exif.ifd0 = ifd0
+	if pointer, ok := exif.uint32Value(ifd0, exifTagExifIFDPointer); ok {
+		// Exif 子 IFD 损坏时仍保留 IFD0 的结果
+		if exifIFD, err := exif.readIFD(pointer); err == nil {
+			exif.exifIFD = exifIFD
+		}
+	}
+	return exif, nil
+}
+
+func (exif *EXIF) readIFD(offset uint32) (map[uint16]exifEntry, error) {
+	if int(offset)+2 > len(exif.raw) {
+		return nil, fmt.Errorf("EXIF IFD 偏移超出范围")
+	}
+	count := int(exif.byteOrder.Uint16(exif.raw[offset : offset+2]))
+	start := int(offset) + 2
+	if start+count*12 > len(exif.raw) {
+		return nil, fmt.Errorf("EXIF IFD 条目超出范围")
+	}
+	entries := make(map[uint16]exifEntry, count)
+	for i := 0; i < count; i++ {
+		entry := exif.raw[start+i*12 : start+(i+1)*12]
+		entries[exif.byteOrder.Uint16(entry[0:2])] = exifEntry{
+			Type:          exif.byteOrder.Uint16(entry[2:4]),
+			Count:         exif.byteOrder.Uint32(entry[4:8]),
+			ValueOrOffset: entry[8:12],
+		}
+	}
+	return entries, nil
+}
+
+func (exif *EXIF) uint32Value(ifd map[uint16]exifEntry, tag uint16) (uint32, bool) {
+	entry, ok := ifd[tag]
+	if !ok || entry.Count < 1 {
+		return 0, false
+	}
+	switch entry.Type {
+	case 3: // SHORT
+		return uint32(exif.byteOrder.Uint16(entry.ValueOrOffset[0:2])), true
+	case 4: // LONG
+		return exif.byteOrder.Uint32(entry.ValueOrOffset), true
+	}
+	return 0, false
+}
+
+// Orientation 返回 IFD0 中的方向值 (1-8), 缺失或无效时返回 1
+func (exif *EXIF) Orientation() int {
+	value, ok := exif.uint32Value(exif.ifd0, exifTagOrientation)
+	if !ok || value < 1 || value > 8 {
+		return 1
+	}
+	return int(value)
+}
+
+func (exif *EXIF) asciiValue(ifd map[uint16]exifEntry, tag uint16) (string, bool) {
+	entry, ok := ifd[tag]
+	if !ok || entry.Type != 2 || entry.Count < 1 {
+		return "", false
+	}
+	value := entry.ValueOrOffset[:min(entry.Count, 4)]
+	if entry.Count > 4 {
+		offset := int(exif.byteOrder.Uint32(entry.ValueOrOffset))
+		if offset+int(entry.Count) > len(exif.raw) {
+			return "", false
+		}
+		value = exif.raw[offset : offset+int(entry.Count)]
+	}
+	return strings.TrimRight(string(value), "\x00 "), true
+}
+
+// DateTimeOriginal 返回 Exif 子 IFD 中的拍摄时间, 按本地时区解析, 缺失或无效时返回 false
+func (exif *EXIF) DateTimeOriginal() (time.Time, bool) {
+	value, ok := exif.asciiValue(exif.exifIFD, exifTagDateTimeOriginal)
+	if !ok {
+		return time.Time{}, false
+	}
+	dateTime, err := time.ParseInLocation(exifDateTimeLayout, value, time.Local)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return dateTime, true
+}
@@ -0,0 +1,81 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+)
+
+type Tag struct {
+	Group0 string
+	Group1 string
+	Name   string
+	Value  string
+}
+
+func (tag Tag) Key() string {
+	return tag.Group0 + ":" + tag.Group1 + ":" + tag.Name
+}
+
+type FileTags struct {
+	SourceFile string
+	Tags       []Tag
+}
+
+// 由 exiftool 生成而非文件本身携带的分组
+var syntheticGroups = map[string]bool{
+	"ExifTool":  true,
+	"File":      true,
+	"Composite": true,
+}
+
+// ReadTags 调用一次 exiftool 批量读取文件的全部标签
+func ReadTags(paths ...string) ([]FileTags, error) {
+	if len(paths) == 0 {
+		return nil, nil
+	}
+	args := append([]string{"-j", "-G0:1", "-a", "-m", "-q", "--"}, paths...)
+	cmd := exec.Command("exiftool", args...)
+	var cmdOut, cmdErr bytes.Buffer
+	cmd.Stdout = &cmdOut
+	cmd.Stderr = &cmdErr
+	// exiftool 在部分文件出错时返回非零, 仍然输出其他文件的结果
+	runErr := cmd.Run()
+	if cmdOut.Len() == 0 {
+		if runErr != nil {
+			return nil, fmt.Errorf("执行 exiftool 命令失败\n%s%w", cmdErr.String(), runErr)
+		}
+		return nil, nil
+	}
+	var rawResults []map[string]any
+	if err := json.Unmarshal(cmdOut.Bytes(), &rawResults); err != nil {
+		return nil, fmt.Errorf("解析 exiftool 输出失败\n%w", err)
+	}
+	results := make([]FileTags, 0, len(rawResults))
+	for _, rawResult := range rawResults {
+		fileTags := FileTags{}
+		if sourceFile, ok := rawResult["SourceFile"].(string); ok {
+			fileTags.SourceFile = sourceFile
+		}
+		for key, value := range rawResult {
+			parts := strings.SplitN(key, ":", 3)
+			if len(parts) != 3 || syntheticGroups[parts[0]] {
+				continue
+			}
+			fileTags.Tags = append(fileTags.Tags, Tag{
+				Group0: parts[0],
+				Group1: parts[1],
+				Name:   parts[2],
+				Value:  fmt.Sprint(value),
+			})
+		}
+		sort.Slice(fileTags.Tags, func(i, j int) bool {
+			return fileTags.Tags[i].Key() < fileTags.Tags[j].Key()
+		})
+		results = append(results, fileTags)
+	}
+	return results, nil
+}
@@ -0,0 +1,198 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"trance-cli/internal/imgfmt"
+)
+
+var (
+	jpegSignature = []byte{0xFF, 0xD8}
+	pngSignature  = []byte("\x89PNG\r\n\x1a\n")
+)
+
+// Stripper 在不重新编码像素数据的前提下移除元数据
+type Stripper func(data []byte) ([]byte, error)
+
+// LookupStripper 按文件内容识别的格式返回进程内实现, 不支持的格式返回 nil
+func LookupStripper(format imgfmt.Format) Stripper {
+	switch format {
+	case imgfmt.JPEG:
+		return StripJPEG
+	case imgfmt.PNG:
+		return StripPNG
+	case imgfmt.WebP:
+		return StripWebP
+	}
+	return nil
+}
+
+// JPEG 中解码所需而保留的 APPn 段, 以段内标识区分同一标记的不同用途
+var jpegKeptAppSegments = map[byte][]byte{
+	// JFIF 像素密度
+	0xE0: []byte("JFIF\x00"),
+	// ICC 色彩配置, 同为 APP2 的 MPF 多图索引会被移除
+	0xE2: []byte("ICC_PROFILE\x00"),
+	// Adobe 色彩变换标志, 影响 CMYK/YCCK 的解码
+	0xEE: []byte("Adobe"),
+}
+
+// StripJPEG 移除除 JFIF、ICC 与 Adobe 以外的全部 APPn 段及 COM 注释, 扫描数据原样保留
+// 输出在 EOI 处截断, EOI 之后的数据 (如 MPF 预览图, 其中带有各自的 EXIF 与 GPS) 一并丢弃
+func StripJPEG(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, jpegSignature) {
+		return nil, fmt.Errorf("不是有效的 JPEG 文件")
+	}
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(jpegSignature)
+	pos := len(jpegSignature)
+	for {
+		if pos >= len(data) {
+			return nil, fmt.Errorf("JPEG 文件在图像数据前结束")
+		}
+		if data[pos] != 0xFF {
+			return nil, fmt.Errorf("JPEG 段标记无效: 偏移 %d", pos)
+		}
+		// 标记前允许任意个 0xFF 填充字节
+		markerPos := pos
+		for markerPos < len(data) && data[markerPos] == 0xFF {
+			markerPos++
+		}
+		if markerPos >= len(data) {
+			return nil, fmt.Errorf("JPEG 文件在段标记处结束")
+		}
+		marker := data[markerPos]
+		segmentStart := markerPos - 1
+		// 无长度字段的独立标记
+		if marker == 0x01 || (marker >= 0xD0 && marker <= 0xD7) {
+			out.Write(data[segmentStart : markerPos+1])
+			pos = markerPos + 1
+			continue
+		}
+		if marker == 0xD9 {
+			out.Write(data[segmentStart : markerPos+1])
+			return out.Bytes(), nil
+		}
+		if markerPos+3 > len(data) {
+			return nil, fmt.Errorf("JPEG 段长度不完整")
+		}
+		length := int(binary.BigEndian.Uint16(data[markerPos+1 : markerPos+3]))
+		segmentEnd := markerPos + 1 + length
+		if length < 2 || segmentEnd > len(data) {
+			return nil, fmt.Errorf("JPEG 段长度无效: 0x%02X", marker)
+		}
+		// SOS 之后为熵编码数据, 原样复制到下一个标记, 渐进式 JPEG 存在多个扫描
+		if marker == 0xDA {
+			out.Write(data[segmentStart:segmentEnd])
+			pos = jpegScanEnd(data, segmentEnd)
+			if pos >= len(data) {
+				return nil, fmt.Errorf("JPEG 文件缺少 EOI 标记")
+			}
+			out.Write(data[segmentEnd:pos])
+			continue
+		}
+		switch {
+		case marker == 0xFE:
+		case marker >= 0xE0 && marker <= 0xEF:
+			if identifier, ok := jpegKeptAppSegments[marker]; ok && bytes.HasPrefix(data[markerPos+3:segmentEnd], identifier) {
+				out.Write(data[segmentStart:segmentEnd])
+			}
+		default:
+			out.Write(data[segmentStart:segmentEnd])
+		}
+		pos = segmentEnd
+	}
+}
+
+// jpegScanEnd 返回熵编码数据之后首个标记的位置, 跳过 0xFF00 填充与 RSTn 标记
+func jpegScanEnd(data []byte, pos int) int {
+	for ; pos+1 < len(data); pos++ {
+		if data[pos] != 0xFF {
+			continue
+		}
+		next := data[pos+1]
+		if next != 0x00 && next != 0xFF && (next < 0xD0 || next > 0xD7) {
+			return pos
+		}
+	}
+	return len(data)
+}
+
+// StripPNG 移除 eXIf、tIME 与 tEXt/iTXt/zTXt 文本块
+func StripPNG(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, pngSignature) {
+		return nil, fmt.Errorf("不是有效的 PNG 文件")
+	}
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(pngSignature)
+	pos := len(pngSignature)
+	for {
+		if pos+8 > len(data) {
+			return nil, fmt.Errorf("PNG 文件缺少 IEND 块")
+		}
+		length := int(binary.BigEndian.Uint32(data[pos : pos+4]))
+		chunkType := string(data[pos+4 : pos+8])
+		// 长度 + 类型 + 数据 + CRC
+		chunkEnd := pos + 12 + length
+		if chunkEnd > len(data) {
+			return nil, fmt.Errorf("PNG 块长度无效: %s", chunkType)
+		}
+		switch chunkType {
+		case "eXIf", "tIME", "tEXt", "iTXt", "zTXt":
+		default:
+			out.Write(data[pos:chunkEnd])
+		}
+		pos = chunkEnd
+		// IEND 之后的数据一并丢弃
+		if chunkType == "IEND" {
+			return out.Bytes(), nil
+		}
+	}
+}
+
+// StripWebP 移除 EXIF 与 XMP 块, 并同步清除 VP8X 中的对应标志位
+func StripWebP(data []byte) ([]byte, error) {
+	if len(data) < 12 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return nil, fmt.Errorf("不是有效的 WebP 文件")
+	}
+	riffEnd := 8 + int(binary.LittleEndian.Uint32(data[4:8]))
+	if riffEnd > len(data) {
+		return nil, fmt.Errorf("WebP RIFF 长度超出文件大小")
+	}
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(data[0:12])
+	pos := 12
+	for pos < riffEnd {
+		if pos+8 > riffEnd {
+			return nil, fmt.Errorf("WebP 块头不完整")
+		}
+		fourCC := string(data[pos : pos+4])
+		size := int(binary.LittleEndian.Uint32(data[pos+4 : pos+8]))
+		// 块数据按偶数字节对齐
+		chunkEnd := pos + 8 + size + size&1
+		if chunkEnd > riffEnd {
+			// 部分编码器省略末尾填充字节
+			if pos+8+size == riffEnd {
+				chunkEnd = riffEnd
+			} else {
+				return nil, fmt.Errorf("WebP 块长度无效: %s", fourCC)
+			}
+		}
+		switch fourCC {
+		case "EXIF", "XMP ":
+		case "VP8X":
+			chunk := bytes.Clone(data[pos:chunkEnd])
+			if len(chunk) > 8 {
+				chunk[8] &^= 0x08 | 0x04
+			}
+			out.Write(chunk)
+		default:
+			out.Write(data[pos:chunkEnd])
+		}
+		pos = chunkEnd
+	}
+	result := out.Bytes()
+	binary.LittleEndian.PutUint32(result[4:8], uint32(len(result)-8))
+	return result, nil
+}